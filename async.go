@@ -0,0 +1,45 @@
+package materialize
+
+import "context"
+
+// lock is a mutex which waiting for can be canceled.
+type lock chan struct{}
+
+func newLock() lock {
+	return make(lock, 1)
+}
+
+func (l lock) Lock() {
+	l <- struct{}{}
+}
+
+func (l lock) Unlock() {
+	<-l
+}
+
+// LockContext locks, or returns an error of ctx when ctx is done before
+// locking.
+func (l lock) LockContext(ctx context.Context) error {
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// MaterializeAsync gets or creates an instance of receiver's type in a new
+// goroutine. The returned channel receives the result of materialization,
+// then it is closed. Read the receiver after receiving the result.
+//
+// It waits for running materialization instead of failing with ErrorBusy,
+// until ctx is done. Don't wait for the result in a factory, use Context.Go
+// instead.
+func (m *Materializer) MaterializeAsync(ctx context.Context, receiver interface{}, queryTags ...string) <-chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- m.materializeRoot(ctx, receiver, queryTags)
+		close(ch)
+	}()
+	return ch
+}
@@ -0,0 +1,152 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaterializeAsync(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	var (
+		foo  *Foo
+		foos [4]*Foo
+	)
+	ch := m.MaterializeAsync(context.Background(), &foo)
+	var chs [4]<-chan error
+	for i := range foos {
+		chs[i] = m.MaterializeAsync(context.Background(), &foos[i])
+	}
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range chs {
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+		if foos[i] != foo {
+			t.Errorf("instance #%d should be cached one", i)
+		}
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel should be closed")
+	}
+}
+
+func TestMaterializeAsync_Canceled(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var foo *Foo
+	if err := <-m.MaterializeAsync(ctx, &foo); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMaterializeAsync_CanceledWaiting(t *testing.T) {
+	m := newTestMaterializer(t)
+	entered, release := make(chan struct{}), make(chan struct{})
+	m.MustAdd(func() *Foo {
+		close(entered)
+		<-release
+		return &Foo{}
+	})
+	m.MustAdd(newBar)
+	defer close(release)
+	go func() {
+		var foo *Foo
+		m.Materialize(&foo)
+	}()
+	<-entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	var bar *Bar
+	select {
+	case err := <-m.MaterializeAsync(ctx, &bar):
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("waiting should be canceled")
+	}
+}
+
+type slowClient struct {
+	id int
+}
+
+func TestContext_Go(t *testing.T) {
+	m := newTestMaterializer(t)
+	const n = 5
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		id := i
+		m.MustAdd(func() *slowClient {
+			// all clients are created concurrently, or this blocks forever.
+			wg.Done()
+			wg.Wait()
+			return &slowClient{id: id}
+		}, string(rune('a'+i)))
+	}
+	m.MustAdd(func(x *Context) ([]*slowClient, error) {
+		clients := make([]*slowClient, n)
+		for i := range clients {
+			x.Go(&clients[i], string(rune('a'+i)))
+		}
+		if err := x.Wait(); err != nil {
+			return nil, err
+		}
+		return clients, nil
+	})
+
+	done := make(chan error, 1)
+	var clients []*slowClient
+	go func() {
+		done <- m.Materialize(&clients)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("clients are not created concurrently")
+	}
+	for i, c := range clients {
+		if c.id != i {
+			t.Errorf("unexpected client #%d: %+v", i, c)
+		}
+	}
+
+	// dependencies are recorded.
+	s := m.Snapshot()
+	if deps := s.Instances[len(s.Instances)-1].Deps; len(deps) != n {
+		t.Errorf("unexpected deps: %+v", deps)
+	}
+}
+
+func TestContext_GoError(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	m.MustAdd(func(x *Context) *Bar {
+		var (
+			foo *Foo
+			s   string
+		)
+		x.Go(&foo).Go(&s)
+		if x.Wait() == nil {
+			t.Error("Wait should fail")
+		}
+		return &Bar{}
+	})
+	var bar *Bar
+	if err := m.Materialize(&bar); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
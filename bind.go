@@ -0,0 +1,69 @@
+package materialize
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Bind declares that an instance of Impl serves Iface with tags. Impl is
+// materialized with the same tags, and its lifecycle is owned by its own
+// factory.
+func Bind[Iface, Impl any](m *Materializer, tags ...string) error {
+	f, err := newBinding(reflect.TypeOf((*Iface)(nil)).Elem(), reflect.TypeOf((*Impl)(nil)).Elem(), tags)
+	if err != nil {
+		return err
+	}
+	f.setSource(1)
+	return m.AddFactory(f)
+}
+
+// Bind declares that an instance of impl type serves iface type with tags.
+// This is reflection equivalent of Bind function.
+func (m *Materializer) Bind(iface, impl reflect.Type, tags ...string) error {
+	f, err := newBinding(iface, impl, tags)
+	if err != nil {
+		return err
+	}
+	f.setSource(1)
+	return m.AddFactory(f)
+}
+
+// newBinding creates a factory for iface type which serves an instance of
+// impl type.
+func newBinding(iface, impl reflect.Type, tags []string) (*Factory, error) {
+	if iface.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("bind: %s is not an interface", iface)
+	}
+	if !impl.AssignableTo(iface) {
+		return nil, fmt.Errorf("bind: %s doesn't implement %s", impl, iface)
+	}
+	queryTags := append([]string(nil), tags...)
+	return &Factory{
+		Type: iface,
+		Func: func(x *Context) (reflect.Value, error) {
+			rv := reflect.New(impl)
+			err := x.m.materialize0(x, rv, impl, queryTags)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			v := reflect.New(iface).Elem()
+			v.Set(rv.Elem())
+			return v, nil
+		},
+		Tags:        newTags(tags),
+		Description: fmt.Sprintf("bound to %s", impl),
+		target: func(m *Materializer) (*Factory, error) {
+			f, _, err := m.query(impl, queryTags)
+			return f, err
+		},
+	}, nil
+}
+
+// WithStrictBinding enables or disables strict binding. With strict binding,
+// an interface is served only by factories for the interface itself,
+// including ones declared by Bind, and implementations of it are not
+// searched.
+func (m *Materializer) WithStrictBinding(enabled bool) *Materializer {
+	m.strict = enabled
+	return m
+}
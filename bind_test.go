@@ -0,0 +1,150 @@
+package materialize
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type countCloser struct {
+	closed int
+}
+
+func (c *countCloser) Foo() {}
+
+func (c *countCloser) Close() {
+	c.closed++
+}
+
+func TestBind(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	m.MustAdd(newFooBar)
+	if err := Bind[Fooer, *FooBar](m); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		var f Fooer
+		if err := m.Materialize(&f); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := f.(*FooBar); !ok {
+			t.Fatalf("bound implementation is not used: %T", f)
+		}
+	}
+}
+
+func TestBind_Tags(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *Foo { return &Foo{id: 1} }, "a")
+	m.MustAdd(func() *Foo { return &Foo{id: 2} }, "b")
+	if err := m.Bind(reflect.TypeOf((*Fooer)(nil)).Elem(), reflect.TypeOf((*Foo)(nil)), "b"); err != nil {
+		t.Fatal(err)
+	}
+	var f Fooer
+	if err := m.Materialize(&f, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if foo, ok := f.(*Foo); !ok || foo.id != 2 {
+		t.Errorf("unexpected instance: %+v", f)
+	}
+}
+
+func TestBind_Strict(t *testing.T) {
+	m := newTestMaterializer(t).WithStrictBinding(true)
+	m.MustAdd(newFoo)
+	var f Fooer
+	if err := m.Materialize(&f); !errors.Is(err, ErrorNotFound) {
+		t.Fatalf("implementations should not be searched: %v", err)
+	}
+	if err := Bind[Fooer, *Foo](m); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Materialize(&f); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.(*Foo); !ok {
+		t.Errorf("unexpected instance: %T", f)
+	}
+}
+
+func TestBind_Lifecycle(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *countCloser { return &countCloser{} })
+	Bind[Fooer, *countCloser](m)
+	var (
+		f Fooer
+		c *countCloser
+	)
+	if err := m.Materialize(&f); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Materialize(&c); err != nil {
+		t.Fatal(err)
+	}
+	if f != c {
+		t.Fatal("bound instance should be shared")
+	}
+	m.CloseAll()
+	if c.closed != 1 {
+		t.Errorf("instance should be closed once: %d", c.closed)
+	}
+}
+
+func TestBind_Error(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := Bind[*Foo, *Foo](m); err == nil || err.Error() != "bind: *materialize.Foo is not an interface" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Bind[Barer, *Foo](m); err == nil || err.Error() != "bind: *materialize.Foo doesn't implement materialize.Barer" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBind_Evict(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *countCloser { return &countCloser{} })
+	Bind[Fooer, *countCloser](m)
+	var f0, f1 Fooer
+	if err := m.Materialize(&f0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Evict(reflect.TypeOf((*Fooer)(nil)).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if c := f0.(*countCloser); c.closed != 1 {
+		t.Errorf("bound instance should be closed: %d", c.closed)
+	}
+	var c *countCloser
+	x := &Context{m: m}
+	if err := x.Materialize(&f1).Materialize(&c).Error(); err != nil {
+		t.Fatal(err)
+	}
+	if f1 == f0 || f1 != c {
+		t.Error("bound instance should be created again")
+	}
+}
+
+func TestBind_Reload(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := AddReloadable[*countCloser](m, func() *countCloser { return &countCloser{} }); err != nil {
+		t.Fatal(err)
+	}
+	Bind[Fooer, *countCloser](m)
+	var f0, f1 Fooer
+	if err := m.Materialize(&f0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(reflect.TypeOf((*Fooer)(nil)).Elem()); err != nil {
+		t.Fatal(err)
+	}
+	if c := f0.(*countCloser); c.closed != 1 {
+		t.Errorf("old instance should be closed: %d", c.closed)
+	}
+	if err := m.Materialize(&f1); err != nil {
+		t.Fatal(err)
+	}
+	if f1 == f0 {
+		t.Error("bound instance should be reloaded")
+	}
+}
@@ -1,9 +1,12 @@
 package materialize
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"reflect"
+	"sync"
+	"time"
 )
 
 type closer0 interface {
@@ -18,69 +21,316 @@ type closer interface {
 
 var closerType = reflect.TypeOf((*closer)(nil)).Elem()
 
-type closerWrapper struct {
-	cl closer
-	l  *log.Logger
+// cacheEntry is a materialized instance with its factory.
+type cacheEntry struct {
+	fac   *Factory
+	val   reflect.Value
+	close func() error
+	check func(context.Context) error
+
+	// deps is factories which this instance depends on.
+	deps []*Factory
+
+	// created and duration are when and how long the instance was created.
+	created  time.Time
+	duration time.Duration
 }
 
-func (w *closerWrapper) Close() {
-	err := w.cl.Close()
-	if err != nil {
-		msg := fmt.Sprintf("failed to %T.Close: %s", w.cl, err)
-		if w.l != nil {
-			w.l.Print(msg)
-		} else {
-			log.Print(msg)
-		}
+// closeValue closes the value if it implements Close() method.
+func (e *cacheEntry) closeValue(fn func(e *cacheEntry, d time.Duration, err error)) {
+	if e.close == nil {
+		return
+	}
+	st := time.Now()
+	err := e.close()
+	if fn != nil {
+		fn(e, time.Since(st), err)
 	}
 }
 
+// closeEntries closes values of entries in reverse order.
+func closeEntries(entries []*cacheEntry, fn func(e *cacheEntry, d time.Duration, err error)) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entries[i].closeValue(fn)
+	}
+}
+
+// building is a state of an instance under creation.
+type building struct {
+	x    *Context
+	done chan struct{}
+	err  error
+
+	// blockedOn counts waits for other factories by the creation. Several
+	// waits happen at once with Context.Go.
+	blockedOn map[*Factory]int
+}
+
 // cache caches materialized instances.
 type cache struct {
-	objs map[*Factory]reflect.Value
-	c0s  []closer0
-	log  *log.Logger
+	mu       sync.Mutex
+	objs     map[*Factory]*cacheEntry
+	entries  []*cacheEntry
+	building map[*Factory]*building
 }
 
 func newCache() *cache {
 	return &cache{
-		objs: map[*Factory]reflect.Value{},
+		objs:     map[*Factory]*cacheEntry{},
+		building: map[*Factory]*building{},
 	}
 }
 
 func (c *cache) getObj(f *Factory) (reflect.Value, bool) {
-	v, ok := c.objs[f]
-	return v, ok
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.objs[f]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return e.val, true
+}
+
+// acquire gets a cached value of the factory for a context x. When the
+// value is under creation by others, this waits for it. When no values are
+// available, this returns a building state to create it with a context cx,
+// which should be passed to finish after creation.
+func (c *cache) acquire(x, cx *Context, f *Factory) (reflect.Value, bool, *building, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if e, ok := c.objs[f]; ok {
+			return e.val, true, nil, nil
+		}
+		b, ok := c.building[f]
+		if !ok {
+			break
+		}
+		if b.x.val != nil {
+			return reflect.ValueOf(b.x.val), true, nil, nil
+		}
+		if c.isCircular(x, f) {
+			return reflect.Value{}, false, nil, fmt.Errorf("not resolved *materialize.Context for %s", f.Type)
+		}
+		c.block(x, f, 1)
+		c.mu.Unlock()
+		<-b.done
+		c.mu.Lock()
+		c.block(x, f, -1)
+		if b.err != nil {
+			return reflect.Value{}, false, nil, b.err
+		}
+	}
+	b := &building{x: cx, done: make(chan struct{})}
+	c.building[f] = b
+	return reflect.Value{}, false, b, nil
 }
 
-func (c *cache) putObj(f *Factory, v reflect.Value) {
-	c.objs[f] = v
+// finish finishes creation of a value with a building state. The value is
+// cached when err is nil.
+func (c *cache) finish(b *building, v reflect.Value, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.building, b.x.f)
+	if err == nil {
+		c.putObj(b.x, v)
+	}
+	b.err = err
+	close(b.done)
+}
 
-	// store v as closer0 if it implements Close() method.
-	if c0 := c.toC0(v); c0 != nil {
-		c.c0s = append(c.c0s, c0)
+// isCircular checks whether waiting for the factory from x makes circular
+// waits.
+func (c *cache) isCircular(x *Context, f *Factory) bool {
+	seen := map[*Factory]bool{}
+	var visit func(cur *Factory) bool
+	visit = func(cur *Factory) bool {
+		if seen[cur] {
+			return false
+		}
+		seen[cur] = true
+		for y := x; y != nil; y = y.p {
+			if y.f == cur {
+				return true
+			}
+		}
+		b, ok := c.building[cur]
+		if !ok {
+			return false
+		}
+		for g := range b.blockedOn {
+			if visit(g) {
+				return true
+			}
+		}
+		return false
 	}
+	return visit(f)
 }
 
-// closeAll closes all values which implements Close() method.
-func (c *cache) closeAll() {
-	for i := len(c.c0s) - 1; i >= 0; i-- {
-		c.c0s[i].Close()
+// block counts up (or down by negative n) waits for the factory by
+// creations for x and its ancestors.
+func (c *cache) block(x *Context, f *Factory, n int) {
+	for y := x; y != nil; y = y.p {
+		b, ok := c.building[y.f]
+		if !ok || b.x != y {
+			continue
+		}
+		if b.blockedOn == nil {
+			b.blockedOn = map[*Factory]int{}
+		}
+		b.blockedOn[f] += n
+		if b.blockedOn[f] <= 0 {
+			delete(b.blockedOn, f)
+		}
 	}
-	c.objs = map[*Factory]reflect.Value{}
-	c.c0s = nil
 }
 
-func (c *cache) toC0(v reflect.Value) closer0 {
+// resolve sets a value for a context under creation temporary.
+func (c *cache) resolve(x *Context, v interface{}) {
+	c.mu.Lock()
+	x.val = v
+	c.mu.Unlock()
+}
+
+// putObj puts a value which created with a context.
+func (c *cache) putObj(x *Context, v reflect.Value) {
+	e := &cacheEntry{
+		fac:  x.f,
+		val:  v,
+		deps: x.deps,
+
+		created:  x.created,
+		duration: x.duration,
+	}
+	if x.f.target == nil {
+		e.close = toCloseFunc(v, x.cleanup)
+		e.check = toCheckFunc(v)
+	}
+	c.objs[x.f] = e
+	c.entries = append(c.entries, e)
+}
+
+// snapshot returns cached entries in order of creation.
+func (c *cache) snapshot() []*cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*cacheEntry(nil), c.entries...)
+}
+
+// closeAll closes all values which implements Close() method, in reverse
+// order of creation. fn is called for each closed value with elapsed time
+// and error of closing.
+func (c *cache) closeAll(fn func(e *cacheEntry, d time.Duration, err error)) {
+	c.mu.Lock()
+	entries := c.entries
+	c.objs = map[*Factory]*cacheEntry{}
+	c.entries = nil
+	c.mu.Unlock()
+	closeEntries(entries, fn)
+}
+
+// evict closes a cached value of the factory and values which depend on it,
+// in reverse order of creation. Those are removed from the cache. fn is
+// called for each closed value same as closeAll.
+func (c *cache) evict(f *Factory, fn func(e *cacheEntry, d time.Duration, err error)) {
+	c.mu.Lock()
+	if _, ok := c.objs[f]; !ok {
+		c.mu.Unlock()
+		return
+	}
+	removed := c.remove(c.dependents(f, nil))
+	c.mu.Unlock()
+	closeEntries(removed, fn)
+}
+
+// dependents collects factories of cached values which depend on a value of
+// the factory transitively, including the factory itself. Values of skipped
+// factories and values which depend only on those are excluded.
+func (c *cache) dependents(f *Factory, skip func(*Factory) bool) map[*Factory]bool {
+	marked := map[*Factory]bool{f: true}
+	for changed := true; changed; {
+		changed = false
+		for _, e := range c.entries {
+			if marked[e.fac] || (skip != nil && skip(e.fac)) {
+				continue
+			}
+			for _, d := range e.deps {
+				if marked[d] {
+					marked[e.fac] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	return marked
+}
+
+// remove removes entries of marked factories, and returns those in order of
+// creation.
+func (c *cache) remove(marked map[*Factory]bool) []*cacheEntry {
+	var rest, removed []*cacheEntry
+	for _, e := range c.entries {
+		if marked[e.fac] {
+			delete(c.objs, e.fac)
+			removed = append(removed, e)
+			continue
+		}
+		rest = append(rest, e)
+	}
+	c.entries = rest
+	return removed
+}
+
+// replace replaces a cached value of the factory of x, and removes its dependents
+// except skipped ones. The new value is placed as the latest one. This
+// returns the old entry and removed dependents, which are not closed yet.
+func (c *cache) replace(x *Context, v reflect.Value, skip func(*Factory) bool) (*cacheEntry, []*cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := x.f
+	old, ok := c.objs[f]
+	if !ok {
+		c.putObj(x, v)
+		return nil, nil
+	}
+	marked := c.dependents(f, skip)
+	delete(marked, f)
+	removed := c.remove(marked)
+	for i, e := range c.entries {
+		if e == old {
+			c.entries = append(c.entries[:i:i], c.entries[i+1:]...)
+			break
+		}
+	}
+	c.putObj(x, v)
+	return old, removed
+}
+
+// toCloseFunc returns a function to close v if it implements Close() method,
+// and to run cleanup function after that.
+func toCloseFunc(v reflect.Value, cleanup func() error) func() error {
+	var cl func() error
 	typ := v.Type()
 	if typ.AssignableTo(closer0Type) {
-		return v.Interface().(closer0)
-	}
-	if typ.AssignableTo(closerType) {
-		return &closerWrapper{
-			cl: v.Interface().(closer),
-			l:  c.log,
+		c0 := v.Interface().(closer0)
+		cl = func() error {
+			c0.Close()
+			return nil
 		}
+	} else if typ.AssignableTo(closerType) {
+		cl = v.Interface().(closer).Close
+	} else if isOutSet(typ) {
+		cl = outCloseFunc(v)
+	}
+	if cleanup == nil {
+		return cl
+	}
+	if cl == nil {
+		return cleanup
+	}
+	return func() error {
+		return errors.Join(cl(), cleanup())
 	}
-	return nil
 }
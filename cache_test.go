@@ -3,7 +3,11 @@ package materialize
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -99,7 +103,7 @@ func TestCloser0(t *testing.T) {
 	if err == nil {
 		t.Error("materialize r0b should be failed")
 	}
-	if err.Error() != "factory failed: factory for *materialize.Res0 returned nil at 1st value" {
+	if err.Error() != fmt.Sprintf("factory failed (registered at %s): factory for *materialize.Res0 returned nil at 1st value", sourceOf(t, m, r0b)) {
 		t.Errorf("unexpected error for r0b: %s", err)
 	}
 }
@@ -149,3 +153,151 @@ func TestCloser(t *testing.T) {
 		t.Fatalf("unexpected logs: %q", s)
 	}
 }
+
+func TestEvict(t *testing.T) {
+	var sink []string
+	n := 0
+	newID := func(s string) string {
+		n++
+		return fmt.Sprintf("%s%d", s, n)
+	}
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *Res0 {
+		return &Res0{&sink, newID("base")}
+	}, "base")
+	m.MustAdd(func() *Res0 {
+		return &Res0{&sink, newID("other")}
+	}, "other")
+	m.MustAdd(func(x *Context) *Res1 {
+		var r0 *Res0
+		x.Materialize(&r0, "base")
+		return &Res1{&sink, newID("mid"), ""}
+	})
+	m.MustAdd(func(x *Context) *FooX {
+		var r1 *Res1
+		x.Materialize(&r1)
+		return &FooX{}
+	})
+
+	var other *Res0
+	if err := m.Materialize(&other, "other"); err != nil {
+		t.Fatalf("failed to materialize Res0(other): %s", err)
+	}
+	var fooX *FooX
+	if err := m.Materialize(&fooX); err != nil {
+		t.Fatalf("failed to materialize FooX: %s", err)
+	}
+
+	if err := m.Evict(reflect.TypeOf((*Res0)(nil)), "base"); err != nil {
+		t.Fatalf("failed to evict: %s", err)
+	}
+	if s := strings.Join(sink, ","); s != "mid3,base2" {
+		t.Fatalf("unexpected sink after evict: %s", s)
+	}
+
+	var fooX2 *FooX
+	if err := m.Materialize(&fooX2); err != nil {
+		t.Fatalf("failed to materialize FooX again: %s", err)
+	}
+	if fooX2 == fooX {
+		t.Fatal("FooX should be created again")
+	}
+	var other2 *Res0
+	if err := m.Materialize(&other2, "other"); err != nil {
+		t.Fatalf("failed to materialize Res0(other) again: %s", err)
+	}
+	if other2 != other {
+		t.Fatal("Res0(other) should be kept")
+	}
+
+	sink = nil
+	m.CloseAll()
+	if s := strings.Join(sink, ","); s != "mid5,base4,other1" {
+		t.Fatalf("unexpected sink after close all: %s", s)
+	}
+
+	if err := m.Evict(reflect.TypeOf((*Bar)(nil))); err == nil {
+		t.Fatal("evict *Bar should be failed")
+	}
+}
+
+func TestEvict_ConcurrentAdd(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			m.MustAdd(newFoo, fmt.Sprintf("tag%d", i))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		runtime.Gosched()
+		if err := m.Evict(reflect.TypeOf((*Foo)(nil))); err != nil {
+			t.Fatalf("failed to evict: %s", err)
+		}
+	}
+	<-done
+}
+
+func TestCleanup(t *testing.T) {
+	var sink []string
+	bb := &bytes.Buffer{}
+	m := newTestMaterializer(t).WithLogger(log.New(bb, "", 0))
+	m.MustAdd(func() (*Res0, func()) {
+		return &Res0{&sink, "res0"}, func() {
+			sink = append(sink, "cleanup0")
+		}
+	})
+	m.MustAdd(func(x *Context) (*Foo, func() error, error) {
+		var r0 *Res0
+		x.Materialize(&r0)
+		return &Foo{}, func() error {
+			sink = append(sink, "cleanupFoo")
+			return errors.New("oops")
+		}, nil
+	})
+	m.MustAdd(func() (*Bar, func() error, error) {
+		return nil, func() error {
+			sink = append(sink, "cleanupBar")
+			return nil
+		}, errors.New("no bar")
+	})
+
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+	var bar *Bar
+	if err := m.Materialize(&bar); err == nil {
+		t.Fatal("materialize *Bar should be failed")
+	}
+	if s := strings.Join(sink, ","); s != "cleanupBar" {
+		t.Fatalf("cleanup of failed factory should be called: %s", s)
+	}
+
+	sink = nil
+	m.CloseAll()
+	if s := strings.Join(sink, ","); s != "cleanupFoo,res0,cleanup0" {
+		t.Fatalf("unexpected sink: %s", s)
+	}
+	if s := bb.String(); s != "failed to *materialize.Foo.Close: oops\n" {
+		t.Fatalf("unexpected logs: %q", s)
+	}
+}
+
+func TestCleanup_InvalidFactory(t *testing.T) {
+	m := newTestMaterializer(t)
+	err := m.Add(func() (*Foo, string, error) { return nil, "", nil })
+	if err == nil || err.Error() != "second of return values should be func() or func() error" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = m.Add(func() (*Foo, func(), string) { return nil, nil, "" })
+	if err == nil || err.Error() != "last of return values should be error" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = m.Add(func() (*Foo, func(), error, error) { return nil, nil, nil, nil })
+	if err != ErrorFactoryRetun {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
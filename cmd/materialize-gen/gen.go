@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	directive   = "//materialize:provide"
+	packagePath = "github.com/koron-go/materialize"
+)
+
+// provider is an annotated constructor.
+type provider struct {
+	name   string
+	pos    token.Position
+	tags   []string
+	params []param
+	result string
+	hasErr bool
+}
+
+// param is a parameter of a constructor.
+type param struct {
+	name  string
+	typ   string
+	isCtx bool
+
+	// tags is tags to select a provider, which is given by "param.NAME".
+	// selected is true when it is given.
+	tags     []string
+	selected bool
+
+	// extern is true when the dependency is provided outside of the
+	// package, which is given by "extern".
+	extern bool
+}
+
+// pkgInfo is a scanned package.
+type pkgInfo struct {
+	name      string
+	providers []*provider
+	// imports maps package names to import paths, which used by providers.
+	imports map[string]string
+}
+
+// parsePackage scans non-test Go files in dir except output.
+func parsePackage(dir, output string) (*pkgInfo, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	fset := token.NewFileSet()
+	pkg := &pkgInfo{imports: map[string]string{}}
+	for _, name := range names {
+		base := filepath.Base(name)
+		if base == output || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if pkg.name == "" {
+			pkg.name = f.Name.Name
+		} else if pkg.name != f.Name.Name {
+			return nil, fmt.Errorf("multiple packages in %s: %s and %s", dir, pkg.name, f.Name.Name)
+		}
+		err = pkg.scanFile(fset, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pkg.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return pkg, nil
+}
+
+func (pkg *pkgInfo) scanFile(fset *token.FileSet, f *ast.File) error {
+	imports := fileImports(f)
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Doc == nil {
+			continue
+		}
+		args, ok := findDirective(fd.Doc)
+		if !ok {
+			continue
+		}
+		pos := fset.Position(fd.Pos())
+		p, err := newProvider(fd, pos, args, imports)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pos, err)
+		}
+		// collect packages which are used by the provider.
+		var uerr error
+		ast.Inspect(fd.Type, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			id, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			ipath, ok := imports[id.Name]
+			if !ok {
+				uerr = fmt.Errorf("%s: unknown package %s", pos, id.Name)
+				return false
+			}
+			if prev, ok := pkg.imports[id.Name]; ok && prev != ipath {
+				uerr = fmt.Errorf("%s: package name %s conflicts: %s and %s", pos, id.Name, prev, ipath)
+				return false
+			}
+			pkg.imports[id.Name] = ipath
+			return false
+		})
+		if uerr != nil {
+			return uerr
+		}
+		pkg.providers = append(pkg.providers, p)
+	}
+	return nil
+}
+
+// fileImports maps package names to import paths for a file.
+func fileImports(f *ast.File) map[string]string {
+	imports := map[string]string{}
+	for _, spec := range f.Imports {
+		ipath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importName(ipath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = ipath
+	}
+	return imports
+}
+
+// importName guesses package name from import path.
+func importName(ipath string) string {
+	name := path.Base(ipath)
+	if strings.HasPrefix(name, "v") && len(name) > 1 && strings.Trim(name[1:], "0123456789") == "" {
+		name = path.Base(path.Dir(ipath))
+	}
+	if i := strings.LastIndex(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// findDirective finds the directive in doc comments, and returns its args.
+func findDirective(doc *ast.CommentGroup) ([]string, bool) {
+	for _, c := range doc.List {
+		if c.Text == directive {
+			return nil, true
+		}
+		if strings.HasPrefix(c.Text, directive+" ") {
+			return strings.Fields(c.Text[len(directive):]), true
+		}
+	}
+	return nil, false
+}
+
+func newProvider(fd *ast.FuncDecl, pos token.Position, args []string, imports map[string]string) (*provider, error) {
+	p := &provider{name: fd.Name.Name, pos: pos}
+	selects := map[string][]string{}
+	externs := map[string]bool{}
+	for _, arg := range args {
+		k, v, ok := strings.Cut(arg, "=")
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("unknown argument for %s: %s", p.name, arg)
+		case k == "tags":
+			p.tags = splitList(v)
+		case k == "extern":
+			for _, name := range splitList(v) {
+				externs[name] = true
+			}
+		case strings.HasPrefix(k, "param.") && len(k) > len("param."):
+			selects[k[len("param."):]] = splitList(v)
+		default:
+			return nil, fmt.Errorf("unknown argument for %s: %s", p.name, arg)
+		}
+	}
+	if fd.Recv != nil {
+		return nil, fmt.Errorf("%s should not be a method", p.name)
+	}
+	if fd.Type.TypeParams != nil {
+		return nil, fmt.Errorf("%s should not have type parameters", p.name)
+	}
+
+	for _, field := range fd.Type.Params.List {
+		if _, ok := field.Type.(*ast.Ellipsis); ok {
+			return nil, fmt.Errorf("%s should not be variadic", p.name)
+		}
+		prm := param{
+			typ:   types.ExprString(field.Type),
+			isCtx: isContext(field.Type, imports),
+		}
+		if len(field.Names) == 0 {
+			p.params = append(p.params, prm)
+			continue
+		}
+		for _, id := range field.Names {
+			prm.name = id.Name
+			prm.tags, prm.selected = selects[id.Name]
+			prm.extern = externs[id.Name]
+			delete(selects, id.Name)
+			delete(externs, id.Name)
+			if prm.isCtx && (prm.selected || prm.extern) {
+				return nil, fmt.Errorf("parameter %s of %s should not be selected", id.Name, p.name)
+			}
+			p.params = append(p.params, prm)
+		}
+	}
+	var unknown []string
+	for name := range selects {
+		unknown = append(unknown, name)
+	}
+	for name := range externs {
+		unknown = append(unknown, name)
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown parameter for %s: %s", p.name, strings.Join(unknown, ", "))
+	}
+
+	var results []ast.Expr
+	if fd.Type.Results != nil {
+		for _, field := range fd.Type.Results.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				results = append(results, field.Type)
+			}
+		}
+	}
+	switch len(results) {
+	case 1:
+	case 2:
+		if id, ok := results[1].(*ast.Ident); !ok || id.Name != "error" {
+			return nil, fmt.Errorf("last of return values of %s should be error", p.name)
+		}
+		p.hasErr = true
+	default:
+		return nil, fmt.Errorf("%s should return 1 or 2 values", p.name)
+	}
+	p.result = types.ExprString(results[0])
+	return p, nil
+}
+
+// splitList splits comma separated values, and drops empty ones.
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// isContext checks an expression is *materialize.Context.
+func isContext(expr ast.Expr, imports map[string]string) bool {
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && imports[id.Name] == packagePath
+}
+
+// resolve finds a provider for each parameter of providers, and checks
+// circular dependencies.
+func (pkg *pkgInfo) resolve() (map[*provider][]*provider, error) {
+	byType := map[string][]*provider{}
+	for _, p := range pkg.providers {
+		byType[p.result] = append(byType[p.result], p)
+	}
+	deps := map[*provider][]*provider{}
+	for _, p := range pkg.providers {
+		for _, prm := range p.params {
+			if prm.isCtx || prm.extern {
+				deps[p] = append(deps[p], nil)
+				continue
+			}
+			cands := byType[prm.typ]
+			if prm.selected {
+				cands = selectProviders(cands, prm.tags)
+			}
+			switch len(cands) {
+			case 0:
+				return nil, fmt.Errorf("%s: missing dependency %s%s for %s", p.pos, prm.typ, tagsNote(prm), p.name)
+			case 1:
+				deps[p] = append(deps[p], cands[0])
+			default:
+				names := make([]string, len(cands))
+				for i, c := range cands {
+					names[i] = c.name
+				}
+				return nil, fmt.Errorf("%s: ambiguous dependency %s%s for %s: provided by %s", p.pos, prm.typ, tagsNote(prm), p.name, strings.Join(names, ", "))
+			}
+		}
+	}
+
+	// detect circular dependencies.
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[*provider]int{}
+	var visit func(p *provider, chain []string) error
+	visit = func(p *provider, chain []string) error {
+		chain = append(chain, p.name)
+		switch state[p] {
+		case visiting:
+			return fmt.Errorf("%s: circular dependency: %s", p.pos, strings.Join(chain, " -> "))
+		case visited:
+			return nil
+		}
+		state[p] = visiting
+		for _, d := range deps[p] {
+			if d == nil {
+				continue
+			}
+			if err := visit(d, chain); err != nil {
+				return err
+			}
+		}
+		state[p] = visited
+		return nil
+	}
+	for _, p := range pkg.providers {
+		if err := visit(p, nil); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// selectProviders selects providers which have all tags. When some of those
+// have exactly same tags, only those are selected.
+func selectProviders(cands []*provider, tags []string) []*provider {
+	var matched, exact []*provider
+	for _, c := range cands {
+		have := map[string]bool{}
+		for _, t := range c.tags {
+			have[t] = true
+		}
+		ok := true
+		for _, t := range tags {
+			if !have[t] {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		matched = append(matched, c)
+		if len(have) == len(tags) {
+			exact = append(exact, c)
+		}
+	}
+	if len(exact) > 0 {
+		return exact
+	}
+	return matched
+}
+
+// tagsNote describes selected tags of a parameter for error messages.
+func tagsNote(prm param) string {
+	if !prm.selected {
+		return ""
+	}
+	return fmt.Sprintf(" tags:%v", prm.tags)
+}
+
+// generate generates source code of the package.
+func generate(pkg *pkgInfo, funcName string) ([]byte, error) {
+	deps, err := pkg.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	mname := "materialize"
+	for name, ipath := range pkg.imports {
+		if ipath == packagePath {
+			mname = name
+		}
+	}
+	if ipath, ok := pkg.imports[mname]; ok && ipath != packagePath {
+		return nil, fmt.Errorf("package name %s conflicts: %s and %s", mname, ipath, packagePath)
+	}
+	for _, std := range []string{"fmt", "reflect"} {
+		if ipath, ok := pkg.imports[std]; ok && ipath != std {
+			return nil, fmt.Errorf("package name %s conflicts: %s", std, ipath)
+		}
+	}
+	imports := map[string]string{
+		mname:     packagePath,
+		"fmt":     "fmt",
+		"reflect": "reflect",
+	}
+	for name, ipath := range pkg.imports {
+		imports[name] = ipath
+	}
+	paths := make([]string, 0, len(imports))
+	names := map[string]string{}
+	for name, ipath := range imports {
+		paths = append(paths, ipath)
+		names[ipath] = name
+	}
+	sort.Strings(paths)
+
+	bb := &bytes.Buffer{}
+	fmt.Fprintf(bb, "// Code generated by materialize-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(bb, "package %s\n\n", pkg.name)
+	fmt.Fprintf(bb, "import (\n")
+	// standard packages first, then others.
+	for _, std := range []bool{true, false} {
+		for _, ipath := range paths {
+			if isStd(ipath) != std {
+				continue
+			}
+			if name := names[ipath]; name != importName(ipath) {
+				fmt.Fprintf(bb, "%s %q\n", name, ipath)
+			} else {
+				fmt.Fprintf(bb, "%q\n", ipath)
+			}
+		}
+		if std {
+			fmt.Fprintf(bb, "\n")
+		}
+	}
+	fmt.Fprintf(bb, ")\n\n")
+
+	fmt.Fprintf(bb, "// %s adds factories which call annotated constructors directly.\n", funcName)
+	fmt.Fprintf(bb, "func %s(m *%s.Materializer) error {\n", funcName, mname)
+	fmt.Fprintf(bb, "for _, f := range []*%s.Factory{\n", mname)
+	for _, p := range pkg.providers {
+		fmt.Fprintf(bb, "{\n")
+		fmt.Fprintf(bb, "Type: reflect.TypeOf((*%s)(nil)).Elem(),\n", p.result)
+		fmt.Fprintf(bb, "Tags: %s,\n", tagsLiteral(mname, p.tags))
+		fmt.Fprintf(bb, "Name: %q,\n", p.name)
+		fmt.Fprintf(bb, "File: %q,\n", filepath.Base(p.pos.Filename))
+		fmt.Fprintf(bb, "Line: %d,\n", p.pos.Line)
+		fmt.Fprintf(bb, "Func: func(x *%s.Context) (reflect.Value, error) {\n", mname)
+		var args []string
+		for i, d := range deps[p] {
+			prm := p.params[i]
+			if prm.isCtx {
+				args = append(args, "x")
+				continue
+			}
+			tags := prm.tags
+			if !prm.selected && d != nil {
+				tags = d.tags
+			}
+			v := fmt.Sprintf("p%d", i)
+			fmt.Fprintf(bb, "var %s %s\n", v, prm.typ)
+			fmt.Fprintf(bb, "x.Materialize(&%s%s)\n", v, tagsArgs(tags))
+			args = append(args, v)
+		}
+		if len(args) > 0 {
+			fmt.Fprintf(bb, "if err := x.Error(); err != nil {\nreturn reflect.Value{}, err\n}\n")
+		}
+		call := fmt.Sprintf("%s(%s)", p.name, strings.Join(args, ", "))
+		if p.hasErr {
+			fmt.Fprintf(bb, "v, err := %s\n", call)
+			fmt.Fprintf(bb, "if err != nil {\nreturn reflect.Value{}, err\n}\n")
+		} else {
+			fmt.Fprintf(bb, "v := %s\n", call)
+		}
+		// same as the check of factories by reflection.
+		fmt.Fprintf(bb, "rv := reflect.ValueOf(&v).Elem()\n")
+		fmt.Fprintf(bb, "switch rv.Kind() {\n")
+		fmt.Fprintf(bb, "case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:\n")
+		fmt.Fprintf(bb, "if rv.IsNil() {\n")
+		fmt.Fprintf(bb, "return reflect.Value{}, fmt.Errorf(\"factory for %%s returned nil at 1st value\", rv.Type())\n")
+		fmt.Fprintf(bb, "}\n")
+		fmt.Fprintf(bb, "}\n")
+		fmt.Fprintf(bb, "return rv, nil\n")
+		fmt.Fprintf(bb, "},\n")
+		fmt.Fprintf(bb, "},\n")
+	}
+	fmt.Fprintf(bb, "} {\n")
+	fmt.Fprintf(bb, "if err := m.AddFactory(f); err != nil {\nreturn err\n}\n")
+	fmt.Fprintf(bb, "}\n")
+	fmt.Fprintf(bb, "return nil\n")
+	fmt.Fprintf(bb, "}\n")
+
+	b, err := format.Source(bb.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return b, nil
+}
+
+// isStd checks an import path is of standard packages.
+func isStd(ipath string) bool {
+	first, _, _ := strings.Cut(ipath, "/")
+	return !strings.Contains(first, ".")
+}
+
+func tagsLiteral(mname string, tags []string) string {
+	if len(tags) == 0 {
+		return mname + ".Tags{}"
+	}
+	items := make([]string, len(tags))
+	for i, t := range tags {
+		items[i] = strconv.Quote(t) + ": {}"
+	}
+	return mname + ".Tags{" + strings.Join(items, ", ") + "}"
+}
+
+func tagsArgs(tags []string) string {
+	var s string
+	for _, t := range tags {
+		s += ", " + strconv.Quote(t)
+	}
+	return s
+}
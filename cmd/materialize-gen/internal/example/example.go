@@ -0,0 +1,76 @@
+// Package example is an example of materialize-gen.
+package example
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/koron-go/materialize"
+)
+
+//go:generate go run ../.. -func addFactories
+
+// Config is configuration.
+type Config struct {
+	Name string
+}
+
+// Options is provided outside of generated code.
+type Options struct {
+	Suffix string
+}
+
+// Greeter greets.
+type Greeter interface {
+	Greet() string
+}
+
+type greeter struct {
+	cfg    *Config
+	suffix string
+	opts   *Options
+}
+
+func (g *greeter) Greet() string {
+	return "hello " + g.cfg.Name + g.suffix + g.opts.Suffix
+}
+
+// NewConfig provides *Config.
+//
+//materialize:provide tags=example
+func NewConfig() *Config {
+	return &Config{Name: "world"}
+}
+
+// NewTestConfig provides *Config for tests.
+//
+//materialize:provide tags=example,test
+func NewTestConfig() *Config {
+	return &Config{Name: "test"}
+}
+
+// NewMissingConfig provides *Config which is missing.
+//
+//materialize:provide tags=missing
+func NewMissingConfig() *Config {
+	return nil
+}
+
+// NewGreeter provides Greeter.
+//
+//materialize:provide param.cfg=example extern=opts
+func NewGreeter(x *materialize.Context, cfg *Config, b *strings.Builder, opts *Options) (Greeter, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("no name")
+	}
+	return &greeter{cfg: cfg, suffix: b.String(), opts: opts}, nil
+}
+
+// NewBuilder provides *strings.Builder.
+//
+//materialize:provide
+func NewBuilder() *strings.Builder {
+	b := &strings.Builder{}
+	b.WriteString("!")
+	return b
+}
@@ -0,0 +1,44 @@
+package example
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/koron-go/materialize"
+)
+
+func TestGenerated(t *testing.T) {
+	m := materialize.New().WithRepository(&materialize.Repository{})
+	m.MustAdd(func() *Options {
+		return &Options{Suffix: "?"}
+	})
+	if err := addFactories(m); err != nil {
+		t.Fatalf("failed to add factories: %s", err)
+	}
+	var g Greeter
+	if err := m.Materialize(&g); err != nil {
+		t.Fatalf("failed to materialize Greeter: %s", err)
+	}
+	if s := g.Greet(); s != "hello world!?" {
+		t.Fatalf("unexpected greeting: %q", s)
+	}
+	var cfg *Config
+	if err := m.Materialize(&cfg, "example"); err != nil {
+		t.Fatalf("failed to materialize *Config: %s", err)
+	}
+	if g.(*greeter).cfg != cfg {
+		t.Fatal("*Config should be shared")
+	}
+}
+
+func TestGenerated_Nil(t *testing.T) {
+	m := materialize.New().WithRepository(&materialize.Repository{})
+	if err := addFactories(m); err != nil {
+		t.Fatalf("failed to add factories: %s", err)
+	}
+	var cfg *Config
+	err := m.Materialize(&cfg, "missing")
+	if err == nil || !strings.HasSuffix(err.Error(), `factory failed ("NewMissingConfig" registered at example.go:55): factory for *example.Config returned nil at 1st value`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
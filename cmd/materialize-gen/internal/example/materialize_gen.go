@@ -0,0 +1,124 @@
+// Code generated by materialize-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/koron-go/materialize"
+)
+
+// addFactories adds factories which call annotated constructors directly.
+func addFactories(m *materialize.Materializer) error {
+	for _, f := range []*materialize.Factory{
+		{
+			Type: reflect.TypeOf((**Config)(nil)).Elem(),
+			Tags: materialize.Tags{"example": {}},
+			Name: "NewConfig",
+			File: "example.go",
+			Line: 41,
+			Func: func(x *materialize.Context) (reflect.Value, error) {
+				v := NewConfig()
+				rv := reflect.ValueOf(&v).Elem()
+				switch rv.Kind() {
+				case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+					if rv.IsNil() {
+						return reflect.Value{}, fmt.Errorf("factory for %s returned nil at 1st value", rv.Type())
+					}
+				}
+				return rv, nil
+			},
+		},
+		{
+			Type: reflect.TypeOf((**Config)(nil)).Elem(),
+			Tags: materialize.Tags{"example": {}, "test": {}},
+			Name: "NewTestConfig",
+			File: "example.go",
+			Line: 48,
+			Func: func(x *materialize.Context) (reflect.Value, error) {
+				v := NewTestConfig()
+				rv := reflect.ValueOf(&v).Elem()
+				switch rv.Kind() {
+				case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+					if rv.IsNil() {
+						return reflect.Value{}, fmt.Errorf("factory for %s returned nil at 1st value", rv.Type())
+					}
+				}
+				return rv, nil
+			},
+		},
+		{
+			Type: reflect.TypeOf((**Config)(nil)).Elem(),
+			Tags: materialize.Tags{"missing": {}},
+			Name: "NewMissingConfig",
+			File: "example.go",
+			Line: 55,
+			Func: func(x *materialize.Context) (reflect.Value, error) {
+				v := NewMissingConfig()
+				rv := reflect.ValueOf(&v).Elem()
+				switch rv.Kind() {
+				case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+					if rv.IsNil() {
+						return reflect.Value{}, fmt.Errorf("factory for %s returned nil at 1st value", rv.Type())
+					}
+				}
+				return rv, nil
+			},
+		},
+		{
+			Type: reflect.TypeOf((*Greeter)(nil)).Elem(),
+			Tags: materialize.Tags{},
+			Name: "NewGreeter",
+			File: "example.go",
+			Line: 62,
+			Func: func(x *materialize.Context) (reflect.Value, error) {
+				var p1 *Config
+				x.Materialize(&p1, "example")
+				var p2 *strings.Builder
+				x.Materialize(&p2)
+				var p3 *Options
+				x.Materialize(&p3)
+				if err := x.Error(); err != nil {
+					return reflect.Value{}, err
+				}
+				v, err := NewGreeter(x, p1, p2, p3)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				rv := reflect.ValueOf(&v).Elem()
+				switch rv.Kind() {
+				case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+					if rv.IsNil() {
+						return reflect.Value{}, fmt.Errorf("factory for %s returned nil at 1st value", rv.Type())
+					}
+				}
+				return rv, nil
+			},
+		},
+		{
+			Type: reflect.TypeOf((**strings.Builder)(nil)).Elem(),
+			Tags: materialize.Tags{},
+			Name: "NewBuilder",
+			File: "example.go",
+			Line: 72,
+			Func: func(x *materialize.Context) (reflect.Value, error) {
+				v := NewBuilder()
+				rv := reflect.ValueOf(&v).Elem()
+				switch rv.Kind() {
+				case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+					if rv.IsNil() {
+						return reflect.Value{}, fmt.Errorf("factory for %s returned nil at 1st value", rv.Type())
+					}
+				}
+				return rv, nil
+			},
+		},
+	} {
+		if err := m.AddFactory(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Command materialize-gen generates reflection-free wiring code for
+// constructors annotated with "//materialize:provide".
+//
+// An annotated constructor accepts its dependencies as parameters, and
+// returns an instance with or without an error:
+//
+//	//materialize:provide tags=primary,rw extern=db
+//	func NewStore(db *sql.DB, cfg *Config) (*Store, error) {
+//		...
+//	}
+//
+// A parameter is resolved to the annotated constructor of its type. When
+// there are several constructors of the type, select one by tags with
+// "param.NAME=tags". A parameter which is provided outside of the package,
+// for example by Materializer.Add, is declared with "extern=NAME,...", and
+// it is materialized with selected tags at run time:
+//
+//	//materialize:provide param.db=primary extern=logger
+//	func NewRepo(db *Store, logger *slog.Logger) *Repo {
+//		...
+//	}
+//
+// The generated function adds factories which call constructors directly to
+// a *materialize.Materializer. Generation fails when a dependency is missing,
+// ambiguous or circular.
+//
+// Usage:
+//
+//	//go:generate materialize-gen [-dir .] [-o materialize_gen.go] [-func addGeneratedFactories]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	var (
+		dir      string
+		output   string
+		funcName string
+	)
+	flag.StringVar(&dir, "dir", ".", "directory of the package to scan")
+	flag.StringVar(&output, "o", "materialize_gen.go", "name of the output file in the directory")
+	flag.StringVar(&funcName, "func", "addGeneratedFactories", "name of the generated function")
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("materialize-gen: ")
+
+	err := run(dir, output, funcName)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(dir, output, funcName string) error {
+	pkg, err := parsePackage(dir, output)
+	if err != nil {
+		return err
+	}
+	b, err := generate(pkg, funcName)
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, output)
+	err = os.WriteFile(name, b, 0666)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
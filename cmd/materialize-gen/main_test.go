@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("internal", "example")
+	pkg, err := parsePackage(dir, "materialize_gen.go")
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	got, err := generate(pkg, "addFactories")
+	if err != nil {
+		t.Fatalf("failed to generate: %s", err)
+	}
+	exp, err := os.ReadFile(filepath.Join(dir, "materialize_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, exp) {
+		t.Fatalf("generated code is outdated, run go generate:\n%s", got)
+	}
+}
+
+func TestGenerate_Error(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		exp  string
+	}{
+		{"missing", "testdata/missing/missing.go:8:1: missing dependency *Bar for NewFoo"},
+		{"ambiguous", "testdata/ambiguous/ambiguous.go:8:1: ambiguous dependency *Bar for NewFoo: provided by NewBarA, NewBarB"},
+		{"circular", "testdata/circular/circular.go:8:1: circular dependency: NewFoo -> NewBar -> NewFoo"},
+		{"missingtags", "testdata/missingtags/missingtags.go:8:1: missing dependency *Bar tags:[c] for NewFoo"},
+		{"unknownparam", "testdata/unknownparam/unknownparam.go:8:1: unknown parameter for NewFoo: baz"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pkg, err := parsePackage(filepath.Join("testdata", tc.name), "materialize_gen.go")
+			if err == nil {
+				_, err = generate(pkg, "addFactories")
+			}
+			if err == nil {
+				t.Fatal("generate should be failed")
+			}
+			if err.Error() != tc.exp {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestImportName(t *testing.T) {
+	for ipath, exp := range map[string]string{
+		"strings":                    "strings",
+		"database/sql":               "sql",
+		"github.com/foo/bar/v2":      "bar",
+		"gopkg.in/yaml.v3":           "yaml",
+		"github.com/mattn/go-isatty": "isatty",
+	} {
+		if got := importName(ipath); got != exp {
+			t.Errorf("unexpected name for %s: %s (expected %s)", ipath, got, exp)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package circular
+
+type Foo struct{}
+
+type Bar struct{}
+
+//materialize:provide
+func NewFoo(bar *Bar) *Foo {
+	return &Foo{}
+}
+
+//materialize:provide
+func NewBar(foo *Foo) (*Bar, error) {
+	return &Bar{}, nil
+}
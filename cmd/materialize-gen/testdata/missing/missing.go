@@ -0,0 +1,10 @@
+package missing
+
+type Foo struct{}
+
+type Bar struct{}
+
+//materialize:provide
+func NewFoo(bar *Bar) *Foo {
+	return &Foo{}
+}
@@ -0,0 +1,20 @@
+package missingtags
+
+type Foo struct{}
+
+type Bar struct{}
+
+//materialize:provide param.bar=c
+func NewFoo(bar *Bar) *Foo {
+	return &Foo{}
+}
+
+//materialize:provide tags=a
+func NewBarA() *Bar {
+	return &Bar{}
+}
+
+//materialize:provide tags=b
+func NewBarB() *Bar {
+	return &Bar{}
+}
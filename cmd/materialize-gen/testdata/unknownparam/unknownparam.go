@@ -0,0 +1,10 @@
+package unknownparam
+
+type Foo struct{}
+
+type Bar struct{}
+
+//materialize:provide param.baz=a
+func NewFoo(bar *Bar) *Foo {
+	return &Foo{}
+}
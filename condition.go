@@ -0,0 +1,51 @@
+package materialize
+
+import "os"
+
+// Condition reports whether a factory is available for a Materializer. It is
+// evaluated at each query. m is nil when queried by Repository.Query
+// directly.
+type Condition func(m *Materializer) bool
+
+// Profile returns a Condition which holds when any of the profiles is active
+// for the Materializer.
+func Profile(names ...string) Condition {
+	return func(m *Materializer) bool {
+		if m == nil {
+			return false
+		}
+		for _, name := range names {
+			if m.profiles[name] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Env returns a Condition which holds when an environment variable has the
+// value.
+func Env(key, value string) Condition {
+	return func(*Materializer) bool {
+		return os.Getenv(key) == value
+	}
+}
+
+// WithProfiles replaces active profiles.
+func (m *Materializer) WithProfiles(names ...string) *Materializer {
+	m.profiles = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.profiles[name] = true
+	}
+	return m
+}
+
+// AddIf adds a function as Factory, which is available only when the
+// condition holds. It wins an unconditional factory with same tags and
+// priority while available. When several conditional factories with same
+// tags and priority are available, the one added first wins.
+func (m *Materializer) AddIf(cond Condition, fn interface{}, tags ...string) error {
+	return m.add(1, fn, tags, func(f *Factory) {
+		f.Cond = cond
+	})
+}
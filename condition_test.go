@@ -0,0 +1,119 @@
+package materialize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfile(t *testing.T) {
+	r := &Repository{}
+	for _, p := range []string{"dev", "prod"} {
+		err := New().WithRepository(r).AddIf(Profile(p), newStringFactory(p))
+		if err != nil {
+			t.Fatalf("failed to add %s: %s", p, err)
+		}
+	}
+	check := func(profile, exp string) {
+		t.Helper()
+		var s string
+		err := New().WithRepository(r).WithProfiles(profile).Materialize(&s)
+		if err != nil {
+			t.Fatalf("failed to materialize for %s: %s", profile, err)
+		}
+		if s != exp {
+			t.Fatalf("unexpected string: %q (expected %q)", s, exp)
+		}
+	}
+	check("dev", "dev")
+	check("prod", "prod")
+
+	var s string
+	err := New().WithRepository(r).WithProfiles("test").Materialize(&s)
+	if err == nil || err.Error() != "not found factory for type:string tags:[]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := r.Query(reflect.TypeOf(""), nil); ok {
+		t.Fatal("Repository.Query should not find factories for profiles")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("MATERIALIZE_TEST", "on")
+	m := newTestMaterializer(t)
+	if err := m.AddIf(Env("MATERIALIZE_TEST", "on"), newStringFactory("on"), "env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddIf(Env("MATERIALIZE_TEST", "off"), newStringFactory("off"), "env"); err != nil {
+		t.Fatal(err)
+	}
+	var s string
+	if err := m.Materialize(&s, "env"); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	if s != "on" {
+		t.Fatalf("unexpected string: %q", s)
+	}
+}
+
+func TestCondition_Predicate(t *testing.T) {
+	enabled := false
+	m := newTestMaterializer(t)
+	err := m.AddIf(func(*Materializer) bool { return enabled }, newFoo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var foo *Foo
+	if err := m.Materialize(&foo); err == nil {
+		t.Fatal("materialize *Foo should be failed")
+	}
+	enabled = true
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+}
+
+func TestCondition_Tie(t *testing.T) {
+	enabled := true
+	r := &Repository{}
+	if err := New().WithRepository(r).Add(newStringFactory("normal")); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().WithRepository(r).AddIf(func(*Materializer) bool { return enabled }, newStringFactory("cond")); err != nil {
+		t.Fatal(err)
+	}
+	check := func(exp string) {
+		t.Helper()
+		// the result should not depend on order of factories.
+		for i := 0; i < 20; i++ {
+			var s string
+			if err := New().WithRepository(r).Materialize(&s); err != nil {
+				t.Fatal(err)
+			}
+			if s != exp {
+				t.Fatalf("unexpected string: %q (expected %q)", s, exp)
+			}
+		}
+	}
+	check("cond")
+	enabled = false
+	check("normal")
+}
+
+func TestCondition_Order(t *testing.T) {
+	r := &Repository{}
+	for _, s := range []string{"a", "b", "c"} {
+		if err := New().WithRepository(r).AddIf(Profile("dev"), newStringFactory(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the result should not depend on order of factories in a map.
+	for i := 0; i < 50; i++ {
+		var s string
+		if err := New().WithRepository(r).WithProfiles("dev").Materialize(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s != "a" {
+			t.Fatalf("the factory added first should win: %q", s)
+		}
+	}
+}
@@ -1,55 +1,154 @@
 package materialize
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 )
 
 // Context is a materialize context, which passed to factory as first argument.
 type Context struct {
-	m *Materializer
-	p *Context
-	f *Factory
+	m   *Materializer
+	p   *Context
+	f   *Factory
+	ctx context.Context
 
-	val interface{}
-	err error
+	// mu protects err, deps and abandoned, which are updated by
+	// goroutines started by Go or by invoke.
+	mu sync.Mutex
+	wg sync.WaitGroup
+
+	val     interface{}
+	err     error
+	deps    []*Factory
+	cleanup func() error
+
+	// abandoned is an error to refuse materialization, after the call of
+	// the factory is abandoned by timeout.
+	abandoned error
+
+	// created and duration are when and how long the factory took.
+	created  time.Time
+	duration time.Duration
 }
 
 func (x *Context) child(f *Factory) *Context {
 	return &Context{
-		m: x.m,
-		p: x,
-		f: f,
+		m:   x.m,
+		p:   x,
+		f:   f,
+		ctx: x.ctx,
+	}
+}
+
+// Context returns context.Context of the materialization.
+func (x *Context) Context() context.Context {
+	if x.ctx == nil {
+		return context.Background()
 	}
+	return x.ctx
 }
 
 // Error returns last happened error if available.
 func (x *Context) Error() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
 	return x.err
 }
 
+// setErr stores an error, when no errors are stored yet.
+func (x *Context) setErr(err error) {
+	x.mu.Lock()
+	if x.err == nil {
+		x.err = err
+	}
+	x.mu.Unlock()
+}
+
+// abandon marks the context abandoned with an error. Materialization with
+// the context and its descendants fails with the error after that.
+func (x *Context) abandon(err error) {
+	x.mu.Lock()
+	x.abandoned = err
+	x.mu.Unlock()
+}
+
+// abandonedErr returns an error when the context or one of its ancestors is
+// abandoned.
+func (x *Context) abandonedErr() error {
+	for ; x != nil; x = x.p {
+		x.mu.Lock()
+		err := x.abandoned
+		x.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Resolve resolves an instance temporary. This cuts circular references.
+// The value should be assignable to the type of the factory, and the factory
+// should return the same value. The error happened is stored to Context.
 func (x *Context) Resolve(v interface{}) *Context {
+	if x.Error() != nil {
+		return x
+	}
+	if x.f == nil {
+		x.setErr(errors.New("no factories to resolve"))
+		return x
+	}
 	if x.val != nil {
-		panic(fmt.Sprintf("have resolved already %s", x.f.Type))
+		x.setErr(fmt.Errorf("have resolved already %s", x.f.Type))
+		return x
+	}
+	if err := x.abandonedErr(); err != nil {
+		x.setErr(err)
+		return x
 	}
 	typ := reflect.TypeOf(v)
-	if typ != x.f.Type {
-		panic(fmt.Sprintf("unmatched type, required type is %s", x.f.Type))
+	if typ == nil || !typ.AssignableTo(x.f.Type) {
+		x.setErr(fmt.Errorf("unmatched type %v, required type is %s", typ, x.f.Type))
+		return x
 	}
-	x.val = v
+	x.m.cache.resolve(x, v)
 	return x
 }
 
 // Materialize materializes an instance with tags.
 func (x *Context) Materialize(receiver interface{}, queryTags ...string) *Context {
-	if x.err != nil {
+	if x.Error() != nil {
+		return x
+	}
+	x.setErr(x.m.materialize(x, receiver, queryTags))
+	return x
+}
+
+// Go materializes an instance with tags in a new goroutine, to create
+// several instances concurrently. Call Wait before using the receiver. The
+// error happened is stored to Context same as Materialize.
+func (x *Context) Go(receiver interface{}, queryTags ...string) *Context {
+	if x.Error() != nil {
 		return x
 	}
-	x.err = x.m.materialize(x, receiver, queryTags)
+	x.wg.Add(1)
+	go func() {
+		defer x.wg.Done()
+		x.setErr(x.m.materialize(x, receiver, queryTags))
+	}()
 	return x
 }
 
+// Wait waits for all materializations started by Go, and returns last
+// happened error if available.
+func (x *Context) Wait() error {
+	x.wg.Wait()
+	return x.Error()
+}
+
 // Option materializes an optional instance with tags.
 // The error happened are not stored to Context.
 func (x *Context) Option(receiver interface{}, queryTags ...string) error {
@@ -69,6 +168,34 @@ func (x *Context) getObj(f *Factory) (reflect.Value, bool, error) {
 	return reflect.Value{}, false, nil
 }
 
+// runCleanup runs a cleanup function which returned by a failed factory.
+func (x *Context) runCleanup() {
+	if x.cleanup != nil {
+		x.cleanup()
+		x.cleanup = nil
+	}
+}
+
+// reset clears states of the context for another attempt of the factory.
+func (x *Context) reset() {
+	x.runCleanup()
+	x.m.cache.resolve(x, nil)
+	x.mu.Lock()
+	x.err = nil
+	x.deps = nil
+	x.mu.Unlock()
+}
+
+// addDep records a factory which the instance of this context depends on.
+func (x *Context) addDep(f *Factory) {
+	if x.f == nil {
+		return
+	}
+	x.mu.Lock()
+	x.deps = append(x.deps, f)
+	x.mu.Unlock()
+}
+
 func (x *Context) typ() reflect.Type {
 	return x.f.Type
 }
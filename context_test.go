@@ -1,6 +1,8 @@
 package materialize
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -210,3 +212,98 @@ func TestContext_Option(t *testing.T) {
 		t.Fatalf("unexpected fooX bar: %+v", fooX.bar)
 	}
 }
+
+type getterImpl struct {
+	peer *getterPeer
+}
+
+type getterPeer struct {
+	g Getter
+}
+
+func (*getterImpl) Get() string { return "got" }
+
+func TestContext_ResolveAssignable(t *testing.T) {
+	m := newTestMaterializer(t)
+	var g0 *getterImpl
+	m.MustAdd(func(x *Context) Getter {
+		g0 = &getterImpl{}
+		x.Resolve(g0).Materialize(&g0.peer)
+		return g0
+	}).MustAdd(func(x *Context) *getterPeer {
+		var g Getter
+		x.Materialize(&g)
+		return &getterPeer{g: g}
+	})
+
+	var g Getter
+	if err := m.Materialize(&g); err != nil {
+		t.Fatalf("failed to Materialize: %s", err)
+	}
+	if g != g0 || g0.peer.g != g0 {
+		t.Fatalf("resolved value is not used: %+v", g0.peer)
+	}
+}
+
+func TestContext_ResolveError(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		resolve interface{}
+		want    string
+	}{
+		{"unmatched", func(x *Context) *Foo {
+			x.Resolve(&Bar{})
+			return &Foo{}
+		}, "unmatched type *materialize.Bar, required type is *materialize.Foo"},
+		{"nil", func(x *Context) *Foo {
+			x.Resolve(nil)
+			return &Foo{}
+		}, "unmatched type <nil>, required type is *materialize.Foo"},
+		{"twice", func(x *Context) *Foo {
+			v := &Foo{}
+			x.Resolve(v).Resolve(v)
+			return v
+		}, "have resolved already *materialize.Foo"},
+		{"returned", func(x *Context) *Foo {
+			x.Resolve(&Foo{id: 1})
+			return &Foo{id: 2}
+		}, "resolved value doesn't matched: resolved=&{1} returned=&{2}"},
+		{"returned nil", func(x *Context) Getter {
+			x.Resolve(&getterImpl{})
+			return nil
+		}, "resolved value doesn't matched: resolved=&{<nil>} returned=<nil>"},
+	} {
+		m := newTestMaterializer(t)
+		m.MustAdd(tc.resolve)
+		rv := reflect.New(reflect.TypeOf(tc.resolve).Out(0))
+		err := m.Materialize(rv.Interface())
+		if err == nil || !strings.HasSuffix(err.Error(), tc.want) {
+			t.Errorf("unexpected error for %s: %v", tc.name, err)
+		}
+	}
+}
+
+func TestContext_ResolveUncomparable(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func(x *Context) map[string]int {
+		v := map[string]int{"a": 1}
+		x.Resolve(v)
+		return v
+	})
+	m.MustAdd(func(x *Context) []string {
+		x.Resolve([]string{"a"})
+		return []string{"a"}
+	})
+	var v map[string]int
+	if err := m.Materialize(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v["a"] != 1 {
+		t.Errorf("unexpected map: %+v", v)
+	}
+	var s []string
+	err := m.Materialize(&s)
+	if err == nil || !strings.HasSuffix(err.Error(), "resolved value doesn't matched: resolved=[a] returned=[a]") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
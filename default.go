@@ -14,12 +14,15 @@ func Materialize(receiver interface{}, queryTags ...string) error {
 
 // Add adds a function as Factory with DefaultMaterializer.
 func Add(fn interface{}, tags ...string) error {
-	return DefaultMaterializer.Add(fn, tags...)
+	return DefaultMaterializer.add(1, fn, tags, nil)
 }
 
 // MustAdd adds a function as Factory.
 func MustAdd(fn interface{}, tags ...string) {
-	DefaultMaterializer.MustAdd(fn, tags...)
+	err := DefaultMaterializer.add(1, fn, tags, nil)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // CloseAll closes all cached values.
@@ -13,11 +13,17 @@ var (
 	ErrorFactoryType = errors.New("factory should be a function")
 
 	// ErrorFactoryRetun shows a factory has unexpected number of return values.
-	ErrorFactoryRetun = errors.New("factory should return 1 or 2 values")
+	ErrorFactoryRetun = errors.New("factory should return 1 to 3 values")
 
 	// ErrorFactoryFirstArg shows a factory should have *materialize.Context as 1st argument.
 	ErrorFactoryFirstArg = errors.New("first should be *materialize.Context if available")
 
 	// ErrorFactoryArgsRule shows a factory should have no arguments or just one argument (*materialize.Context).
 	ErrorFactoryArgsRule = errors.New("factory should accept no params or only *materialize.Context")
+
+	// ErrorNotFound shows no factories found for a query.
+	ErrorNotFound = errors.New("not found factory")
+
+	// ErrorNotReloadable shows a factory is not added as reloadable.
+	ErrorNotReloadable = errors.New("factory is not reloadable")
 )
@@ -3,6 +3,7 @@ package materialize
 import (
 	"fmt"
 	"reflect"
+	"time"
 )
 
 // FactoryFunc creates an instance.
@@ -13,17 +14,68 @@ type Factory struct {
 	Type reflect.Type
 	Func FactoryFunc
 	Tags Tags
-}
 
-func (f *Factory) newInstance(x *Context) (reflect.Value, error) {
-	return f.Func(x.child(f))
+	// Name is a human-readable name of the factory. It is optional.
+	Name string
+
+	// Description describes the factory. It is optional.
+	Description string
+
+	// File and Line are the source location where the factory is
+	// registered. Those are empty when unknown.
+	File string
+	Line int
+
+	// Priority is a priority of the factory. When factories have same
+	// score, the one with higher priority is selected. Factories with
+	// negative priority are used only if no other factories match.
+	Priority int
+
+	// Retry is a policy to retry the factory when it fails. The factory is
+	// not retried when it is nil.
+	Retry *RetryPolicy
+
+	// Timeout limits time to create an instance, including retries. There
+	// is no limit when it is zero.
+	Timeout time.Duration
+
+	// Cond is a condition to make the factory available. The factory is
+	// always available when it is nil.
+	Cond Condition
+
+	// handle is a factory of *Reloadable[T] for reloadable factory.
+	handle *Factory
+
+	// module is name of Module which the factory belongs to.
+	module string
+
+	// target is available for an alias, a factory which serves an instance
+	// of another factory. It returns the other factory. The instance is not
+	// closed by the alias.
+	target func(m *Materializer) (*Factory, error)
 }
 
 var (
-	errType = reflect.TypeOf((*error)(nil)).Elem()
-	ctxType = reflect.TypeOf((*Context)(nil))
+	errType      = reflect.TypeOf((*error)(nil)).Elem()
+	ctxType      = reflect.TypeOf((*Context)(nil))
+	cleanup0Type = reflect.TypeOf((func())(nil))
+	cleanupType  = reflect.TypeOf((func() error)(nil))
 )
 
+func isCleanupType(typ reflect.Type) bool {
+	return typ == cleanup0Type || typ == cleanupType
+}
+
+// NewFactory creates a Factory from a function with tags.
+func NewFactory(fn interface{}, tags ...string) (*Factory, error) {
+	f, err := newFactory(fn, tags)
+	if err != nil {
+		return nil, err
+	}
+	f.setSource(1)
+	return f, nil
+}
+
 func newFactory(fn interface{}, tags []string) (*Factory, error) {
 	rfn := reflect.ValueOf(fn)
 	ft := rfn.Type()
@@ -37,21 +89,34 @@ func newFactory(fn interface{}, tags []string) (*Factory, error) {
 		typ  reflect.Type
 	)
 
-	switch ft.NumOut() {
+	switch n := ft.NumOut(); n {
 	case 1:
 		outP.checkLen(1)
-		typ = ft.Out(0)
 	case 2:
+		outP.checkLen(2)
+		if isCleanupType(ft.Out(1)) {
+			outP.checkCleanup(1)
+			break
+		}
 		// second of outs should be `error`.
 		if !ft.Out(1).AssignableTo(errType) {
 			return nil, fmt.Errorf("last of return values should be error")
 		}
-		outP.checkLen(2)
 		outP.checkErr(1)
-		typ = ft.Out(0)
+	case 3:
+		if !isCleanupType(ft.Out(1)) {
+			return nil, fmt.Errorf("second of return values should be func() or func() error")
+		}
+		if !ft.Out(2).AssignableTo(errType) {
+			return nil, fmt.Errorf("last of return values should be error")
+		}
+		outP.checkLen(3)
+		outP.checkCleanup(1)
+		outP.checkErr(2)
 	default:
 		return nil, ErrorFactoryRetun
 	}
+	typ = ft.Out(0)
 
 	switch ft.NumIn() {
 	case 0:
@@ -107,14 +172,23 @@ func (ps *outProcs) checkLen(expect int) {
 
 func (ps *outProcs) checkZero() {
 	ps.add(func(x *Context, out []reflect.Value) error {
-		o0 := out[0]
-		if o0.Kind() == reflect.Ptr && o0.IsNil() {
+		if isNil(out[0]) {
 			return fmt.Errorf("factory for %s returned nil at 1st value", x.typ())
 		}
 		return nil
 	})
 }
 
+// isNil checks whether v is nil, for kinds which can be nil.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Func, reflect.Chan, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
 func (ps *outProcs) checkErr(nerr int) {
 	ps.add(func(x *Context, out []reflect.Value) error {
 		rerr := out[nerr]
@@ -126,22 +200,65 @@ func (ps *outProcs) checkErr(nerr int) {
 	})
 }
 
+// checkCleanup stores a cleanup function to the context.
+func (ps *outProcs) checkCleanup(ncleanup int) {
+	ps.add(func(x *Context, out []reflect.Value) error {
+		rc := out[ncleanup]
+		if rc.IsNil() {
+			return nil
+		}
+		switch fn := rc.Interface().(type) {
+		case func():
+			x.cleanup = func() error {
+				fn()
+				return nil
+			}
+		case func() error:
+			x.cleanup = fn
+		}
+		return nil
+	})
+}
+
 func (ps *outProcs) checkCtx() {
 	ps.add(func(x *Context, out []reflect.Value) error {
-		if x.err != nil {
-			return x.err
+		if err := x.Error(); err != nil {
+			return err
 		}
 		if x.val == nil {
 			return nil
 		}
 		v := out[0].Interface()
-		if v == x.val {
+		if sameValue(v, x.val) {
 			return nil
 		}
-		panic(fmt.Sprintf("resolved value doesn't matched: resolved=%v returned=%v", x.val, v))
+		return fmt.Errorf("resolved value doesn't matched: resolved=%v returned=%v", x.val, v)
 	})
 }
 
+// sameValue checks whether two values are identical. Values of reference
+// kinds are compared by their pointers, because some of them are not
+// comparable. Other values which are not comparable are compared deeply.
+func sameValue(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	if !va.Type().Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
 // wrapFunc wraps a factory func can be used.
 func wrapFunc(typ reflect.Type, fn reflect.Value, inP inProc, outP outProcs) FactoryFunc {
 	return func(x *Context) (reflect.Value, error) {
@@ -150,12 +267,14 @@ func wrapFunc(typ reflect.Type, fn reflect.Value, inP inProc, outP outProcs) Fac
 		for _, p := range outP {
 			err := p(x, out)
 			if err != nil {
+				x.runCleanup()
 				return zv, err
 			}
 		}
 		// check context
-		if x.err != nil {
-			return zv, x.err
+		if err := x.Error(); err != nil {
+			x.runCleanup()
+			return zv, err
 		}
 		// XXX: verify these invalid codes.
 		//if x.val != nil {
@@ -164,32 +283,104 @@ func wrapFunc(typ reflect.Type, fn reflect.Value, inP inProc, outP outProcs) Fac
 	}
 }
 
+// key returns a key in factorySet. Factories with different priorities
+// don't conflict. Conditional factories have unique keys, those never
+// conflict with others.
+func (f *Factory) key() string {
+	k := f.Tags.joinKeys()
+	if f.Priority != 0 {
+		k += fmt.Sprintf("\x00%d", f.Priority)
+	}
+	if f.Cond != nil {
+		k += fmt.Sprintf("\x00%p", f)
+	}
+	return k
+}
+
 type factorySet map[string]*Factory
 
 func (fs factorySet) add(f *Factory) error {
-	k := f.Tags.joinKeys()
-	if _, ok := fs[k]; ok {
-		return fmt.Errorf("duplicated factory for type:%s tags:%+v", f.Type, f.Tags)
+	k := f.key()
+	if prev, ok := fs[k]; ok {
+		if prev.module != "" || f.module != "" {
+			return fmt.Errorf("duplicated factory for type:%s tags:%+v: module %q conflicts with module %q%s", f.Type, f.Tags.list(), f.module, prev.module, conflictOrigins(f, prev))
+		}
+		return fmt.Errorf("duplicated factory for type:%s tags:%+v%s", f.Type, f.Tags, conflictOrigins(f, prev))
 	}
 	fs[k] = f
 	return nil
 }
 
-func (fs factorySet) find(mf *matchedFactory, tags Tags) *matchedFactory {
+func (fs factorySet) clone() factorySet {
+	fs2 := make(factorySet, len(fs))
+	for k, f := range fs {
+		fs2[k] = f
+	}
+	return fs2
+}
+
+// find finds the best matched factory in the set, or returns mf when it is
+// better. order is registration order of factories to break ties.
+func (fs factorySet) find(m *Materializer, mf *matchedFactory, tags Tags, order map[*Factory]uint64) *matchedFactory {
 	for _, f := range fs {
+		if f.Cond != nil && !f.Cond(m) {
+			continue
+		}
 		sc := f.Tags.score(tags)
-		if sc >= 0 && (mf == nil || mf.sc < sc) {
-			mf = &matchedFactory{
-				fac: f,
-				sc:  sc,
-			}
-			// FIXME: log matchedFactory
+		if sc < 0 {
+			continue
+		}
+		c := &matchedFactory{
+			fac:   f,
+			sc:    sc,
+			order: order[f],
+		}
+		if mf == nil || c.better(mf) {
+			mf = c
 		}
 	}
 	return mf
 }
 
 type matchedFactory struct {
-	fac *Factory
-	sc  int
+	fac   *Factory
+	sc    int
+	order uint64
+}
+
+// better compares with other by fallback or not, score, priority,
+// conditional or not and registration order. A conditional factory which is
+// available wins an unconditional one, and the one added earlier wins the
+// rest of ties for the same type. For different types, other wins ties.
+func (mf *matchedFactory) better(other *matchedFactory) bool {
+	fb0, fb1 := mf.fac.Priority < 0, other.fac.Priority < 0
+	if fb0 != fb1 {
+		return fb1
+	}
+	if mf.sc != other.sc {
+		return mf.sc > other.sc
+	}
+	if mf.fac.Priority != other.fac.Priority {
+		return mf.fac.Priority > other.fac.Priority
+	}
+	if c0, c1 := mf.fac.Cond != nil, other.fac.Cond != nil; c0 != c1 {
+		return c0
+	}
+	return mf.fac.Type == other.fac.Type && mf.order < other.order
+}
+
+// conflictOrigins describes origins of conflicted factories for error
+// messages. It returns an empty string when both are unknown.
+func conflictOrigins(f, prev *Factory) string {
+	o, po := f.origin(), prev.origin()
+	if o == "" && po == "" {
+		return ""
+	}
+	if o == "" {
+		o = "unknown"
+	}
+	if po == "" {
+		po = "unknown"
+	}
+	return fmt.Sprintf(" (%s, previously %s)", o, po)
 }
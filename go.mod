@@ -1,3 +1,3 @@
 module github.com/koron-go/materialize
 
-go 1.19
+go 1.21
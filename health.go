@@ -0,0 +1,142 @@
+package materialize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthChecker is implemented by instances which can check its health.
+// Materializer.Health calls Check of all cached instances which implement
+// it.
+type HealthChecker interface {
+	Check(ctx context.Context) error
+}
+
+var healthCheckerType = reflect.TypeOf((*HealthChecker)(nil)).Elem()
+
+// DefaultHealthTimeout is default timeout for each health check.
+var DefaultHealthTimeout = 5 * time.Second
+
+// HealthStatus is a result of health check for an instance.
+type HealthStatus struct {
+	Type     string        `json:"type"`
+	Tags     []string      `json:"tags"`
+	Duration time.Duration `json:"duration"`
+	Err      error         `json:"-"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// WithHealthTimeout replaces timeout for each health check.
+func (m *Materializer) WithHealthTimeout(d time.Duration) *Materializer {
+	m.healthTimeout = d
+	return m
+}
+
+// Health checks health of all cached instances which implement
+// HealthChecker concurrently. Results are ordered by creation of instances.
+func (m *Materializer) Health(ctx context.Context) []HealthStatus {
+	var entries []*cacheEntry
+	for _, e := range m.cache.snapshot() {
+		if e.check != nil {
+			entries = append(entries, e)
+		}
+	}
+
+	timeout := m.healthTimeout
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	results := make([]HealthStatus, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *cacheEntry) {
+			defer wg.Done()
+			st := time.Now()
+			err := runCheck(ctx, timeout, e.check)
+			results[i] = HealthStatus{
+				Type:     e.fac.Type.String(),
+				Tags:     e.fac.Tags.list(),
+				Duration: time.Since(st),
+				Err:      err,
+			}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, e)
+	}
+	wg.Wait()
+	return results
+}
+
+// runCheck runs a check with timeout. It returns without waiting the check
+// when the timeout is expired.
+func runCheck(ctx context.Context, timeout time.Duration, check func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ch := make(chan error, 1)
+	go func() {
+		ch <- check(ctx)
+	}()
+	select {
+	case err := <-ch:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// toCheckFunc returns a function to check v if it implements HealthChecker.
+func toCheckFunc(v reflect.Value) func(context.Context) error {
+	if v.Type().AssignableTo(healthCheckerType) {
+		return v.Interface().(HealthChecker).Check
+	}
+	if isOutSet(v.Type()) {
+		return outCheckFunc(v)
+	}
+	return nil
+}
+
+type healthResponse struct {
+	Status     string         `json:"status"`
+	Components []HealthStatus `json:"components,omitempty"`
+}
+
+// HealthHandler returns http.Handler which serves liveness at paths end with
+// "/livez" and readiness at paths end with "/readyz" as JSON. Readiness
+// responds 503 Service Unavailable if any health checks failed.
+func (m *Materializer) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/livez"):
+			writeHealth(w, http.StatusOK, healthResponse{Status: "ok"})
+		case strings.HasSuffix(r.URL.Path, "/readyz"):
+			res := healthResponse{
+				Status:     "ok",
+				Components: m.Health(r.Context()),
+			}
+			code := http.StatusOK
+			for _, s := range res.Components {
+				if s.Err != nil {
+					res.Status = "error"
+					code = http.StatusServiceUnavailable
+					break
+				}
+			}
+			writeHealth(w, code, res)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, res healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,114 @@
+package materialize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type checker struct {
+	err   error
+	delay time.Duration
+}
+
+func (c *checker) Check(ctx context.Context) error {
+	if c.delay > 0 {
+		time.Sleep(c.delay)
+	}
+	return c.err
+}
+
+func newHealthMaterializer(t *testing.T, checkers map[string]*checker) *Materializer {
+	t.Helper()
+	m := newTestMaterializer(t)
+	for tag, c := range checkers {
+		c := c
+		m.MustAdd(func() *checker { return c }, tag)
+	}
+	for tag := range checkers {
+		var c *checker
+		if err := m.Materialize(&c, tag); err != nil {
+			t.Fatalf("failed to materialize checker(%s): %s", tag, err)
+		}
+	}
+	return m
+}
+
+func TestHealth(t *testing.T) {
+	m := newHealthMaterializer(t, map[string]*checker{
+		"ok":   {},
+		"ng":   {err: errors.New("broken")},
+		"slow": {delay: time.Second},
+	}).WithHealthTimeout(50 * time.Millisecond)
+	m.MustAdd(newFoo)
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+
+	results := m.Health(context.Background())
+	if len(results) != 3 {
+		t.Fatalf("unexpected number of results: %+v", results)
+	}
+	for _, r := range results {
+		if r.Type != "*materialize.checker" || len(r.Tags) != 1 {
+			t.Errorf("unexpected result: %+v", r)
+			continue
+		}
+		switch r.Tags[0] {
+		case "ok":
+			if r.Err != nil {
+				t.Errorf("unexpected error for ok: %s", r.Err)
+			}
+		case "ng":
+			if r.Err == nil || r.Error != "broken" {
+				t.Errorf("unexpected error for ng: %v", r.Err)
+			}
+		case "slow":
+			if !errors.Is(r.Err, context.DeadlineExceeded) {
+				t.Errorf("unexpected error for slow: %v", r.Err)
+			}
+		}
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	get := func(h http.Handler, path string) (int, healthResponse) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		var res healthResponse
+		if rec.Code != http.StatusNotFound {
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("failed to parse response: %s", err)
+			}
+		}
+		return rec.Code, res
+	}
+
+	h := newHealthMaterializer(t, map[string]*checker{"ok": {}}).HealthHandler()
+	if code, res := get(h, "/health/livez"); code != 200 || res.Status != "ok" {
+		t.Errorf("unexpected liveness: %d %+v", code, res)
+	}
+	if code, res := get(h, "/health/readyz"); code != 200 || res.Status != "ok" || len(res.Components) != 1 {
+		t.Errorf("unexpected readiness: %d %+v", code, res)
+	}
+	if code, _ := get(h, "/health/unknown"); code != 404 {
+		t.Errorf("unexpected status for unknown: %d", code)
+	}
+
+	h = newHealthMaterializer(t, map[string]*checker{
+		"ok": {},
+		"ng": {err: errors.New("broken")},
+	}).HealthHandler()
+	if code, res := get(h, "/health/livez"); code != 200 || res.Status != "ok" {
+		t.Errorf("unexpected liveness: %d %+v", code, res)
+	}
+	if code, res := get(h, "/health/readyz"); code != 503 || res.Status != "error" || len(res.Components) != 2 {
+		t.Errorf("unexpected readiness: %d %+v", code, res)
+	}
+}
@@ -0,0 +1,81 @@
+package materialize
+
+import (
+	"reflect"
+	"time"
+)
+
+// Event describes an event of materialization, which passed to Hooks.
+type Event struct {
+	// Type is a type of the instance. For OnQuery it is the queried type.
+	Type reflect.Type
+
+	// Tags is tags of the factory. For OnQuery it is the query tags.
+	Tags []string
+
+	// Factory is the factory of the instance. It is nil when OnQuery didn't
+	// find any factories.
+	Factory *Factory
+
+	// Value is the instance. It is available for OnCreateDone, OnCacheHit
+	// and OnClose.
+	Value reflect.Value
+
+	// Score is a score of the selected factory. It is available for OnQuery
+	// only.
+	Score int
+
+	// Duration is elapsed time of the operation.
+	Duration time.Duration
+
+	// Err is an error of the operation if available.
+	Err error
+}
+
+// Hooks is a set of functions which are called on materialization events.
+// Nil functions are ignored.
+type Hooks struct {
+	// OnQuery is called after querying a factory for a type.
+	OnQuery func(Event)
+
+	// OnCreateStart is called before creating an instance with a factory.
+	OnCreateStart func(Event)
+
+	// OnCreateDone is called after creating an instance with a factory.
+	OnCreateDone func(Event)
+
+	// OnCacheHit is called when an instance is taken from the cache.
+	OnCacheHit func(Event)
+
+	// OnClose is called after closing an instance.
+	OnClose func(Event)
+}
+
+func (m *Materializer) emit(sel func(*Hooks) func(Event), ev Event) {
+	if fn := sel(&m.statsHooks); fn != nil {
+		fn(ev)
+	}
+	for i := range m.hooks {
+		if fn := sel(&m.hooks[i]); fn != nil {
+			fn(ev)
+		}
+	}
+	if fn := sel(&m.slogHooks); fn != nil {
+		fn(ev)
+	}
+}
+
+func onQuery(h *Hooks) func(Event)       { return h.OnQuery }
+func onCreateStart(h *Hooks) func(Event) { return h.OnCreateStart }
+func onCreateDone(h *Hooks) func(Event)  { return h.OnCreateDone }
+func onCacheHit(h *Hooks) func(Event)    { return h.OnCacheHit }
+func onClose(h *Hooks) func(Event)       { return h.OnClose }
+
+// factoryEvent creates an Event for the factory.
+func factoryEvent(f *Factory) Event {
+	return Event{
+		Type:    f.Type,
+		Tags:    f.Tags.list(),
+		Factory: f,
+	}
+}
@@ -0,0 +1,121 @@
+package materialize
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+type hookRecorder struct {
+	logs []string
+}
+
+func (r *hookRecorder) hook(name string) func(Event) {
+	return func(ev Event) {
+		s := fmt.Sprintf("%s type:%s tags:%v", name, ev.Type, ev.Tags)
+		if ev.Err != nil {
+			s += " err:" + ev.Err.Error()
+		}
+		r.logs = append(r.logs, s)
+	}
+}
+
+func (r *hookRecorder) hooks() Hooks {
+	return Hooks{
+		OnQuery:       r.hook("query"),
+		OnCreateStart: r.hook("start"),
+		OnCreateDone:  r.hook("done"),
+		OnCacheHit:    r.hook("hit"),
+		OnClose:       r.hook("close"),
+	}
+}
+
+func (r *hookRecorder) check(t *testing.T, exp ...string) {
+	t.Helper()
+	if got := strings.Join(r.logs, "\n"); got != strings.Join(exp, "\n") {
+		t.Fatalf("unexpected hook logs:\n%s\nexpected:\n%s", got, strings.Join(exp, "\n"))
+	}
+	r.logs = nil
+}
+
+func TestHooks(t *testing.T) {
+	var sink []string
+	rec := &hookRecorder{}
+	m := newTestMaterializer(t).WithHooks(rec.hooks())
+	m.MustAdd(func(x *Context) *FooX {
+		v := &FooX{}
+		x.Materialize(&v.foo)
+		return v
+	}, "x")
+	m.MustAdd(newFoo)
+	m.MustAdd(func() *Res0 { return &Res0{sink: &sink, id: "res0"} })
+
+	var fooX *FooX
+	if err := m.Materialize(&fooX, "x"); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	rec.check(t,
+		"query type:*materialize.FooX tags:[x]",
+		"start type:*materialize.FooX tags:[x]",
+		"query type:*materialize.Foo tags:[]",
+		"start type:*materialize.Foo tags:[]",
+		"done type:*materialize.Foo tags:[]",
+		"done type:*materialize.FooX tags:[x]",
+	)
+
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	var r0 *Res0
+	if err := m.Materialize(&r0); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	var bar *Bar
+	if err := m.Materialize(&bar); err == nil {
+		t.Fatal("materialize *Bar should be failed")
+	}
+	rec.check(t,
+		"query type:*materialize.Foo tags:[]",
+		"hit type:*materialize.Foo tags:[]",
+		"query type:*materialize.Res0 tags:[]",
+		"start type:*materialize.Res0 tags:[]",
+		"done type:*materialize.Res0 tags:[]",
+		"query type:*materialize.Bar tags:[] err:not found factory for type:*materialize.Bar tags:[]",
+	)
+
+	m.CloseAll()
+	rec.check(t, "close type:*materialize.Res0 tags:[]")
+}
+
+func TestHooks_Error(t *testing.T) {
+	var sink []string
+	rec := &hookRecorder{}
+	m := newTestMaterializer(t).
+		WithLogger(log.New(io.Discard, "", 0)).
+		WithHooks(rec.hooks())
+	m.MustAdd(func() (*Bar, error) { return nil, errors.New("no bar") })
+	m.MustAdd(func() *Res1 { return &Res1{sink: &sink, id: "res1", errStr: "close failed"} })
+
+	var bar *Bar
+	if err := m.Materialize(&bar); err == nil {
+		t.Fatal("materialize *Bar should be failed")
+	}
+	rec.check(t,
+		"query type:*materialize.Bar tags:[]",
+		"start type:*materialize.Bar tags:[]",
+		"done type:*materialize.Bar tags:[] err:factory for *materialize.Bar failed: no bar",
+	)
+
+	var r1 *Res1
+	if err := m.Materialize(&r1); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	rec.logs = nil
+
+	m.CloseAll()
+	rec.check(t, "close type:*materialize.Res1 tags:[] err:close failed")
+}
@@ -1,27 +1,77 @@
 package materialize
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"reflect"
-	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Materializer manages materialize instances.
 type Materializer struct {
-	mu    sync.Mutex
+	mu    lock
 	cache *cache
 	repo  *Repository
 	log   *log.Logger
+	slog  *slog.Logger
+	hooks []Hooks
 
-	currRootX *Context
+	// statsHooks and slogHooks are hooks to collect statistics and to log
+	// events with slog, which are called with hooks.
+	statsHooks Hooks
+	slogHooks  Hooks
+
+	stats *stats
+
+	healthTimeout time.Duration
+	recoverPanic  bool
+	strict        bool
+
+	installed map[*Module]bool
+	profiles  map[string]bool
+
+	currRootX atomic.Pointer[Context]
 }
 
 // New creates a Materializer.
 func New() *Materializer {
-	return &Materializer{
+	m := &Materializer{
+		mu:    newLock(),
 		cache: newCache(),
+		stats: newStats(),
 	}
+	m.statsHooks = m.stats.hooks()
+	return m
+}
+
+// Fork creates a new Materializer whose Repository starts with all factories
+// of m. Factories added to the new one don't affect m, and vice versa. The
+// new one has an empty cache, and inherits loggers, hooks and other options
+// of m.
+func (m *Materializer) Fork() *Materializer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m2 := New().WithRepository(m.getRepo().fork())
+	m2.log = m.log
+	m2.slog = m.slog
+	m2.slogHooks = m.slogHooks
+	m2.hooks = append(m2.hooks, m.hooks...)
+	m2.healthTimeout = m.healthTimeout
+	m2.recoverPanic = m.recoverPanic
+	m2.strict = m.strict
+	m2.installed = make(map[*Module]bool, len(m.installed))
+	for k, v := range m.installed {
+		m2.installed[k] = v
+	}
+	m2.profiles = make(map[string]bool, len(m.profiles))
+	for k, v := range m.profiles {
+		m2.profiles[k] = v
+	}
+	return m2
 }
 
 // WithRepository replaces a Repository.
@@ -33,31 +83,46 @@ func (m *Materializer) WithRepository(r *Repository) *Materializer {
 // WithLogger replaces a *log.Logger.
 func (m *Materializer) WithLogger(l *log.Logger) *Materializer {
 	m.log = l
-	m.cache.log = l
 	return m
 }
 
-// unused, disabled
-//func (m *Materializer) logf(format string, args ...interface{}) {
-//	if m.log == nil {
-//		log.Printf(format, args...)
-//		return
-//	}
-//	m.log.Printf(format, args...)
-//}
+// WithHooks adds Hooks, which are called on materialization events.
+func (m *Materializer) WithHooks(h Hooks) *Materializer {
+	m.hooks = append(m.hooks, h)
+	return m
+}
+
+func (m *Materializer) logf(format string, args ...interface{}) {
+	if m.log == nil {
+		log.Printf(format, args...)
+		return
+	}
+	m.log.Printf(format, args...)
+}
 
 // Materialize gets or creates an instance of receiver's type.
 func (m *Materializer) Materialize(receiver interface{}, queryTags ...string) error {
-	if m.currRootX != nil {
+	if m.currRootX.Load() != nil {
 		return ErrorBusy
 	}
-	m.mu.Lock()
+	return m.materializeRoot(context.Background(), receiver, queryTags)
+}
+
+// materializeRoot materializes an instance with a root context, after
+// running materialization finished.
+func (m *Materializer) materializeRoot(ctx context.Context, receiver interface{}, queryTags []string) error {
+	if err := m.mu.LockContext(ctx); err != nil {
+		return err
+	}
 	defer func() {
-		m.currRootX = nil
+		m.currRootX.Store(nil)
 		m.mu.Unlock()
 	}()
-	x := &Context{m: m}
-	m.currRootX = x
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	x := &Context{m: m, ctx: ctx}
+	m.currRootX.Store(x)
 	return m.materialize(x, receiver, queryTags)
 }
 
@@ -66,49 +131,122 @@ func (m *Materializer) materialize(x *Context, receiver interface{}, queryTags [
 	if rv.Kind() != reflect.Ptr {
 		return ErrorReceiverType
 	}
-	typ := rv.Type().Elem()
-
-	switch typ.Kind() {
-	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
-		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
-		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64,
-		reflect.Interface, reflect.Ptr, reflect.String:
-		return m.materialize0(x, rv, typ, queryTags)
-	default:
-		return fmt.Errorf("unsupported type:%s kind:%s", typ, typ.Kind())
-	}
+	return m.materialize0(x, rv, rv.Type().Elem(), queryTags)
 }
 
 // materialize0 materializes an object for the factory.
 func (m *Materializer) materialize0(x *Context, rv reflect.Value, typ reflect.Type, queryTags []string) error {
-	f, ok := m.getRepo().Query(typ, queryTags)
-	if !ok {
-		return fmt.Errorf("not found factory for type:%s tags:%+v", typ, queryTags)
+	st := time.Now()
+	f, sc, err := m.query(typ, queryTags)
+	m.emit(onQuery, Event{Type: typ, Tags: queryTags, Factory: f, Score: sc, Duration: time.Since(st), Err: err})
+	if err != nil {
+		return err
 	}
+	return m.materializeFactory(x, rv, f)
+}
 
+// query queries a factory for the type with tags, and returns it with its
+// score.
+func (m *Materializer) query(typ reflect.Type, queryTags []string) (*Factory, int, error) {
+	mf := m.getRepo().query(m, typ, newTags(queryTags))
+	if mf == nil {
+		return nil, 0, fmt.Errorf("%w for type:%s tags:%+v", ErrorNotFound, typ, queryTags)
+	}
+	return mf.fac, mf.sc, nil
+}
+
+// materializeFactory gets or creates an object with the factory.
+func (m *Materializer) materializeFactory(x *Context, rv reflect.Value, f *Factory) error {
+	if err := x.abandonedErr(); err != nil {
+		return err
+	}
 	v0, ok, err := x.getObj(f)
 	if err != nil {
 		return err
 	} else if ok {
+		x.addDep(f)
 		rv.Elem().Set(v0)
 		return nil
 	}
 
-	if v, ok := m.cache.getObj(f); ok {
+	cx := x.child(f)
+	v, ok, b, err := m.cache.acquire(x, cx, f)
+	if err != nil {
+		return err
+	} else if ok {
+		ev := factoryEvent(f)
+		ev.Value = v
+		m.emit(onCacheHit, ev)
+		x.addDep(f)
 		rv.Elem().Set(v)
 		return nil
 	}
 
-	v, err := f.newInstance(x)
+	finished := false
+	defer func() {
+		if !finished {
+			// a panic is passing through, release waiters for the factory.
+			m.cache.finish(b, reflect.Value{}, fmt.Errorf("factory for %s panicked", f.Type))
+		}
+	}()
+	v, err = m.create(cx)
 	if err != nil {
-		return fmt.Errorf("factory failed: %w", err)
+		err = f.failed(err)
 	}
-	m.cache.putObj(f, v)
+	m.cache.finish(b, v, err)
+	finished = true
+	if err != nil {
+		return err
+	}
+	x.addDep(f)
 	rv.Elem().Set(v)
 
 	return nil
 }
 
+// create creates a new instance with a context for the factory.
+func (m *Materializer) create(x *Context) (reflect.Value, error) {
+	ev := factoryEvent(x.f)
+	m.emit(onCreateStart, ev)
+	st := time.Now()
+	v, err := invoke(x)
+	ev.Duration, ev.Err = time.Since(st), err
+	x.created, x.duration = st, ev.Duration
+	if err == nil {
+		ev.Value = v
+	}
+	m.emit(onCreateDone, ev)
+	return v, err
+}
+
+// Repository returns the Repository which the Materializer uses.
+func (m *Materializer) Repository() *Repository {
+	return m.getRepo()
+}
+
+// Replace replaces factories which have same type and tags with a factory,
+// regardless of their priorities and conditions. It returns a function to
+// restore the replaced factories.
+func (m *Materializer) Replace(f *Factory) (restore func() error, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.getRepo()
+	prevs, err := r.Replace(f)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		r.Remove(f)
+		var errs []error
+		for _, g := range prevs {
+			errs = append(errs, r.Add(g))
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
 func (m *Materializer) getRepo() *Repository {
 	if m.repo != nil {
 		return m.repo
@@ -118,7 +256,7 @@ func (m *Materializer) getRepo() *Repository {
 
 // MustAdd adds a function as Factory.
 func (m *Materializer) MustAdd(fn interface{}, tags ...string) *Materializer {
-	err := m.Add(fn, tags...)
+	err := m.add(1, fn, tags, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -127,23 +265,61 @@ func (m *Materializer) MustAdd(fn interface{}, tags ...string) *Materializer {
 
 // Add adds a function as Factory.
 func (m *Materializer) Add(fn interface{}, tags ...string) error {
-	f, err := newFactory(fn, tags)
-	if err != nil {
-		return err
-	}
+	return m.add(1, fn, tags, nil)
+}
+
+// AddFactory adds a Factory.
+func (m *Materializer) AddFactory(f *Factory) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	err = m.getRepo().Add(f)
-	if err != nil {
-		return err
-	}
-	return nil
+	return m.getRepo().Add(f)
 }
 
 // CloseAll closes all values which implements Close() method, and clear value
 // cache.
 func (m *Materializer) CloseAll() {
 	m.mu.Lock()
-	m.cache.closeAll()
+	m.cache.closeAll(m.closed)
 	m.mu.Unlock()
 }
+
+// Evict closes a cached instance for the type with tags, and all cached
+// instances which depend on it. Those are created again by next
+// materialization. For a type which is declared by Bind or a field of an
+// output set, the instance which serves it is evicted.
+func (m *Materializer) Evict(typ reflect.Type, queryTags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.queryTarget(typ, queryTags)
+	if err != nil {
+		return err
+	}
+	m.cache.evict(f, m.closed)
+	return nil
+}
+
+// queryTarget queries a factory for the type with tags, and resolves aliases
+// to the factory which owns the instance.
+func (m *Materializer) queryTarget(typ reflect.Type, queryTags []string) (*Factory, error) {
+	f, _, err := m.query(typ, queryTags)
+	seen := map[*Factory]bool{}
+	for err == nil && f.target != nil {
+		if seen[f] {
+			return nil, fmt.Errorf("circular aliases for type:%s tags:%+v", typ, queryTags)
+		}
+		seen[f] = true
+		f, err = f.target(m)
+	}
+	return f, err
+}
+
+// closed is called when a cached value is closed.
+func (m *Materializer) closed(e *cacheEntry, d time.Duration, err error) {
+	// when only slog is available, failures are logged by its hook.
+	if err != nil && (m.log != nil || m.slog == nil) {
+		m.logf("failed to %T.Close: %s", e.val.Interface(), err)
+	}
+	ev := factoryEvent(e.fac)
+	ev.Value, ev.Duration, ev.Err = e.val, d, err
+	m.emit(onClose, ev)
+}
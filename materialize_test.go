@@ -2,7 +2,11 @@ package materialize
 
 import (
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestMaterializer(t *testing.T) *Materializer {
@@ -157,7 +161,7 @@ func TestMaterializeError(t *testing.T) {
 	if err == nil {
 		t.Fatal("Materialize(*Bar) should failed")
 	}
-	if err.Error() != "factory failed: factory for *materialize.Bar failed: no bars found" {
+	if err.Error() != fmt.Sprintf("factory failed (registered at %s): factory for *materialize.Bar failed: no bars found", sourceOf(t, m, bar)) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
@@ -204,7 +208,160 @@ func TestRecursiveMaterializationError(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Materialize(*Foo) should failed")
 	}
-	if err.Error() != "factory failed: factory for *materialize.Foo failed: busy or recursive materialization, try materialize.Context#Materialize() instead" {
+	if err.Error() != fmt.Sprintf("factory failed (registered at %s): factory for *materialize.Foo failed: busy or recursive materialization, try materialize.Context#Materialize() instead", sourceOf(t, m, foo)) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestFork(t *testing.T) {
+	base := newTestMaterializer(t)
+	base.MustAdd(newFoo)
+	base.MustAdd(newStringFactory("base"))
+	var baseFoo *Foo
+	if err := base.Materialize(&baseFoo); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+
+	checkString := func(t *testing.T, m *Materializer, exp string) {
+		t.Helper()
+		var s string
+		if err := m.Materialize(&s); err != nil {
+			t.Fatalf("failed to materialize string: %s", err)
+		}
+		if s != exp {
+			t.Fatalf("unexpected string: %q (expected %q)", s, exp)
+		}
+	}
+
+	t.Run("override", func(t *testing.T) {
+		t.Parallel()
+		m := base.Fork()
+		f, err := NewFactory(newStringFactory("fork"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Replace(f); err != nil {
+			t.Fatal(err)
+		}
+		m.MustAdd(newBar)
+		checkString(t, m, "fork")
+		var bar *Bar
+		if err := m.Materialize(&bar); err != nil {
+			t.Fatalf("failed to materialize *Bar: %s", err)
+		}
+		var foo *Foo
+		if err := m.Materialize(&foo); err != nil {
+			t.Fatalf("failed to materialize *Foo: %s", err)
+		}
+		if foo == baseFoo {
+			t.Fatal("forked Materializer should have an empty cache")
+		}
+	})
+
+	t.Run("plain", func(t *testing.T) {
+		t.Parallel()
+		m := base.Fork()
+		checkString(t, m, "base")
+		var bar *Bar
+		if err := m.Materialize(&bar); err == nil {
+			t.Fatal("*Bar should not be found")
+		}
+	})
+
+	t.Run("base", func(t *testing.T) {
+		m := base.Fork()
+		base.MustAdd(newStringFactory("added"), "added")
+		var s string
+		if err := m.Materialize(&s, "added"); err != nil {
+			t.Fatalf("failed to materialize string: %s", err)
+		}
+		if s != "base" {
+			t.Fatalf("factories added to base should not affect forks: %q", s)
+		}
+	})
+
+	t.Cleanup(func() {
+		checkString(t, base, "base")
+		var bar *Bar
+		if err := base.Materialize(&bar); err == nil {
+			t.Fatal("*Bar should not be added to base")
+		}
+	})
+}
+
+func TestFork_Hooks(t *testing.T) {
+	var n int
+	base := newTestMaterializer(t).WithHooks(Hooks{
+		OnCreateDone: func(Event) { n++ },
+	})
+	base.MustAdd(newFoo)
+	m := base.Fork()
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("hooks should be inherited once: %d", n)
+	}
+	if st := m.Stats(); len(st.Factories) != 1 || st.Factories[0].Created != 1 {
+		t.Errorf("unexpected stats of the fork: %+v", st)
+	}
+	if st := base.Stats(); len(st.Factories) != 0 {
+		t.Errorf("stats of base should not be updated: %+v", st)
+	}
+}
+
+type testConfig struct {
+	Name string
+	Port int
+}
+
+func TestMaterializeKinds(t *testing.T) {
+	m := newTestMaterializer(t)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ch := make(chan int)
+	m.MustAdd(func() []string { return []string{"a", "b"} })
+	m.MustAdd(func() map[string]int { return map[string]int{"max": 10} })
+	m.MustAdd(func() func() time.Time { return func() time.Time { return now } })
+	m.MustAdd(func() chan int { return ch })
+	m.MustAdd(func() testConfig { return testConfig{Name: "srv", Port: 80} })
+	m.MustAdd(func() [2]int { return [2]int{1, 2} })
+
+	var (
+		list  []string
+		lim   map[string]int
+		clock func() time.Time
+		c     chan int
+		cfg   testConfig
+		arr   [2]int
+	)
+	x := &Context{m: m}
+	err := x.Materialize(&list).Materialize(&lim).Materialize(&clock).Materialize(&c).Materialize(&cfg).Materialize(&arr).Error()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []string{"a", "b"}) || lim["max"] != 10 || !clock().Equal(now) || c != ch || cfg.Port != 80 || arr != [2]int{1, 2} {
+		t.Errorf("unexpected values: %v %v %v %v %+v %v", list, lim, clock(), c, cfg, arr)
+	}
+}
+
+func TestMaterializeNilResults(t *testing.T) {
+	for _, tc := range []struct {
+		fn  interface{}
+		rcv interface{}
+	}{
+		{func() []string { return nil }, new([]string)},
+		{func() map[string]int { return nil }, new(map[string]int)},
+		{func() func() { return nil }, new(func())},
+		{func() chan int { return nil }, new(chan int)},
+		{func() error { return nil }, new(error)},
+	} {
+		m := newTestMaterializer(t)
+		m.MustAdd(tc.fn)
+		err := m.Materialize(tc.rcv)
+		typ := reflect.TypeOf(tc.rcv).Elem()
+		if err == nil || !strings.HasSuffix(err.Error(), fmt.Sprintf("factory for %s returned nil at 1st value", typ)) {
+			t.Errorf("unexpected error for %s: %v", typ, err)
+		}
+	}
+}
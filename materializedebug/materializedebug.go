@@ -0,0 +1,95 @@
+// Package materializedebug serves state of a Materializer via HTTP, for
+// debugging live processes.
+//
+// The package is typically only imported for the side effect of registering
+// its HTTP handler for materialize.DefaultMaterializer, like net/http/pprof.
+//
+//	import _ "github.com/koron-go/materialize/materializedebug"
+//
+// The handler path is /debug/materialize. It serves HTML by default, and
+// JSON when requested with "?format=json" or "Accept: application/json".
+//
+// To serve another Materializer, use Handler.
+//
+//	mux.Handle("/debug/materialize", materializedebug.Handler(m))
+package materializedebug
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/koron-go/materialize"
+)
+
+func init() {
+	http.Handle("/debug/materialize", Handler(materialize.DefaultMaterializer))
+}
+
+// Handler returns an HTTP handler which serves a snapshot of the
+// Materializer.
+func Handler(m *materialize.Materializer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := m.Snapshot()
+		if wantJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(s)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := page.Execute(w, s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func wantJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+var page = template.Must(template.New("page").Funcs(template.FuncMap{
+	"ref": func(ref materialize.FactoryRef) string {
+		if len(ref.Tags) == 0 {
+			return ref.Type
+		}
+		return ref.Type + " " + strings.Join(ref.Tags, ",")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>/debug/materialize</title>
+<style>
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<p><a href="?format=json">JSON</a></p>
+
+<h2>Factories ({{len .Factories}})</h2>
+<table>
+<tr><th>Type</th><th>Tags</th><th>Name</th><th>Description</th><th>Source</th><th>Priority</th><th>Conditional</th><th>Module</th></tr>
+{{range .Factories}}<tr><td>{{.Type}}</td><td>{{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td>{{.Name}}</td><td>{{.Description}}</td><td>{{.Source}}</td><td>{{.Priority}}</td><td>{{.Conditional}}</td><td>{{.Module}}</td></tr>
+{{end}}</table>
+
+<h2>Instances ({{len .Instances}})</h2>
+<table>
+<tr><th>Type</th><th>Tags</th><th>Name</th><th>Created</th><th>Duration</th><th>Depends on</th></tr>
+{{range .Instances}}<tr><td>{{.Type}}</td><td>{{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td>{{.Name}}</td><td>{{.Created.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Duration}}</td><td>{{range .Deps}}{{ref .}}<br>{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Close errors ({{len .CloseErrors}})</h2>
+<table>
+<tr><th>Time</th><th>Type</th><th>Tags</th><th>Name</th><th>Error</th></tr>
+{{range .CloseErrors}}<tr><td>{{.Time.Format "2006-01-02T15:04:05.000Z07:00"}}</td><td>{{.Type}}</td><td>{{range $i, $t := .Tags}}{{if $i}}, {{end}}{{$t}}{{end}}</td><td>{{.Name}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
@@ -0,0 +1,88 @@
+package materializedebug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/koron-go/materialize"
+)
+
+type Foo struct{}
+
+func newTestMaterializer(t *testing.T) *materialize.Materializer {
+	t.Helper()
+	m := materialize.New().WithRepository(&materialize.Repository{})
+	err := m.AddNamed("foo", "Foo for <debug>", func() *Foo { return &Foo{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.CloseAll)
+	return m
+}
+
+func serve(h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_HTML(t *testing.T) {
+	h := Handler(newTestMaterializer(t))
+	rec := serve(h, "/debug/materialize", nil)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	body := rec.Body.String()
+	for _, s := range []string{
+		"<h2>Factories (1)</h2>",
+		"<h2>Instances (1)</h2>",
+		"*materializedebug.Foo",
+		"Foo for &lt;debug&gt;",
+		"materializedebug_test.go:",
+	} {
+		if !strings.Contains(body, s) {
+			t.Errorf("body should contain %q:\n%s", s, body)
+		}
+	}
+}
+
+func TestHandler_JSON(t *testing.T) {
+	h := Handler(newTestMaterializer(t))
+	for _, tc := range []struct {
+		target string
+		header http.Header
+	}{
+		{"/debug/materialize?format=json", nil},
+		{"/debug/materialize", http.Header{"Accept": {"application/json"}}},
+	} {
+		rec := serve(h, tc.target, tc.header)
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("unexpected content type for %s: %s", tc.target, ct)
+		}
+		var s materialize.Snapshot
+		if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+			t.Fatalf("failed to decode %s: %s", tc.target, err)
+		}
+		if len(s.Factories) != 1 || s.Factories[0].Name != "foo" || len(s.Instances) != 1 || s.Instances[0].Type != "*materializedebug.Foo" {
+			t.Errorf("unexpected snapshot: %+v", s)
+		}
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	_, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/materialize", nil))
+	if pattern != "/debug/materialize" {
+		t.Errorf("handler is not registered: %q", pattern)
+	}
+}
@@ -0,0 +1,132 @@
+// Package materializetest provides utilities for tests with materialize.
+package materializetest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/koron-go/materialize"
+)
+
+type closer0 interface {
+	Close()
+}
+
+type closer interface {
+	Close() error
+}
+
+// tracker tracks instances which implement Close() method.
+type tracker struct {
+	mu   sync.Mutex
+	open map[interface{}]string
+}
+
+func (tr *tracker) created(ev materialize.Event) {
+	// instances of aliases are closed by other factories.
+	if ev.Err != nil || !ev.Value.IsValid() || ev.Factory.Alias() {
+		return
+	}
+	v := ev.Value.Interface()
+	switch v.(type) {
+	case closer0, closer:
+	default:
+		return
+	}
+	if !ev.Value.Type().Comparable() {
+		return
+	}
+	tr.mu.Lock()
+	tr.open[v] = fmt.Sprintf("%s tags:%+v", ev.Type, ev.Tags)
+	tr.mu.Unlock()
+}
+
+func (tr *tracker) closed(ev materialize.Event) {
+	if !ev.Value.IsValid() || !ev.Value.Type().Comparable() {
+		return
+	}
+	tr.mu.Lock()
+	delete(tr.open, ev.Value.Interface())
+	tr.mu.Unlock()
+}
+
+// New creates a Materializer with an empty Repository for a test. All
+// instances are closed at the end of the test. The test fails when closing
+// an instance failed, or when an instance is left open, for example one
+// created by a forked Materializer which is not closed.
+func New(t testing.TB) *materialize.Materializer {
+	t.Helper()
+	tr := &tracker{open: map[interface{}]string{}}
+	m := materialize.New().WithRepository(&materialize.Repository{})
+	m.WithHooks(materialize.Hooks{
+		OnCreateDone: tr.created,
+		OnClose: func(ev materialize.Event) {
+			tr.closed(ev)
+			if ev.Err != nil {
+				t.Errorf("failed to close %s tags:%+v: %s", ev.Type, ev.Tags, ev.Err)
+			}
+		},
+	})
+	t.Cleanup(func() {
+		m.CloseAll()
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		for _, s := range tr.open {
+			t.Errorf("instance is left open: %s", s)
+		}
+	})
+	return m
+}
+
+// Override replaces factories which have same type and tags with a function
+// for a test, regardless of their priorities and conditions. The replaced
+// factories are restored at the end of the test. This should be called
+// before materialization of the type.
+func Override(t testing.TB, m *materialize.Materializer, fn interface{}, tags ...string) {
+	t.Helper()
+	f, err := materialize.NewFactory(fn, tags...)
+	if err != nil {
+		t.Fatalf("failed to create a factory: %s", err)
+	}
+	restore, err := m.Replace(f)
+	if err != nil {
+		t.Fatalf("failed to override a factory: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := restore(); err != nil {
+			t.Errorf("failed to restore factories: %s", err)
+		}
+	})
+}
+
+// AssertMaterializes materializes an instance of T with tags, or fails the
+// test.
+func AssertMaterializes[T any](t testing.TB, m *materialize.Materializer, tags ...string) T {
+	t.Helper()
+	var v T
+	err := m.Materialize(&v, tags...)
+	if err != nil {
+		t.Fatalf("failed to materialize %s tags:%+v: %s", typeName[T](), tags, err)
+	}
+	return v
+}
+
+// AssertNotFound asserts no factories are found for T with tags.
+func AssertNotFound[T any](t testing.TB, m *materialize.Materializer, tags ...string) {
+	t.Helper()
+	var v T
+	err := m.Materialize(&v, tags...)
+	if err == nil {
+		t.Fatalf("factory found for %s tags:%+v", typeName[T](), tags)
+	}
+	if !errors.Is(err, materialize.ErrorNotFound) {
+		t.Fatalf("unexpected error for %s tags:%+v: %s", typeName[T](), tags, err)
+	}
+}
+
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
@@ -0,0 +1,213 @@
+package materializetest
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/koron-go/materialize"
+)
+
+// fakeT records failures and cleanups.
+type fakeT struct {
+	testing.TB
+	errs     []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Fatalf(format string, args ...interface{}) {
+	t.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+func (t *fakeT) Cleanup(fn func()) {
+	t.cleanups = append(t.cleanups, fn)
+}
+
+// run runs fn with fakeT, and returns recorded failures.
+func run(fn func(t *fakeT)) []string {
+	ft := &fakeT{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ft)
+	}()
+	<-done
+	for i := len(ft.cleanups) - 1; i >= 0; i-- {
+		ft.cleanups[i]()
+	}
+	return ft.errs
+}
+
+type res struct {
+	err    error
+	closed *bool
+}
+
+func (r *res) Close() error {
+	*r.closed = true
+	return r.err
+}
+
+type item struct {
+	name string
+}
+
+func TestNew(t *testing.T) {
+	closed := false
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() *res { return &res{closed: &closed} })
+		AssertMaterializes[*res](t, m)
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+	if !closed {
+		t.Fatal("instance should be closed at cleanup")
+	}
+}
+
+type resSet struct {
+	materialize.Out
+
+	Res *res
+}
+
+func TestNew_OutSet(t *testing.T) {
+	closed := false
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() resSet { return resSet{Res: &res{closed: &closed}} })
+		AssertMaterializes[*res](t, m)
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+	if !closed {
+		t.Fatal("field should be closed at cleanup")
+	}
+}
+
+func TestNew_LeftOpen(t *testing.T) {
+	closed := false
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() *res { return &res{closed: &closed} })
+		// instances of the fork are not closed by the cleanup of m.
+		AssertMaterializes[*res](t, m.Fork())
+	})
+	if len(errs) != 1 || errs[0] != "instance is left open: *materializetest.res tags:[]" {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+	if closed {
+		t.Fatal("instance of the fork should not be closed")
+	}
+}
+
+func TestNew_CloseError(t *testing.T) {
+	closed := false
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() *res { return &res{err: errors.New("oops"), closed: &closed} }, "foo")
+		AssertMaterializes[*res](t, m, "foo")
+	})
+	if len(errs) != 1 || errs[0] != "failed to close *materializetest.res tags:[foo]: oops" {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() *item { return &item{"original"} })
+		run(func(t2 *fakeT) {
+			Override(t2, m, func() *item { return &item{"override"} })
+			if v := AssertMaterializes[*item](t, m); v.name != "override" {
+				t.Errorf("unexpected item: %s", v.name)
+			}
+			m.CloseAll()
+		})
+		if v := AssertMaterializes[*item](t, m); v.name != "original" {
+			t.Errorf("factory should be restored: %s", v.name)
+		}
+		m.CloseAll()
+
+		run(func(t2 *fakeT) {
+			Override(t2, m, func() string { return "added" }, "new")
+		})
+		AssertNotFound[string](t, m, "new")
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+}
+
+func TestOverride_Priority(t *testing.T) {
+	errs := run(func(t *fakeT) {
+		m := New(t).WithProfiles("dev")
+		if err := m.AddPrimary(func() *item { return &item{"primary"} }); err != nil {
+			t.Fatalf("failed to add: %s", err)
+		}
+		if err := m.AddIf(materialize.Profile("dev"), func() *item { return &item{"dev"} }, "env"); err != nil {
+			t.Fatalf("failed to add: %s", err)
+		}
+		run(func(t2 *fakeT) {
+			Override(t2, m, func() *item { return &item{"override"} })
+			Override(t2, m, func() *item { return &item{"override env"} }, "env")
+			if v := AssertMaterializes[*item](t, m); v.name != "override" {
+				t.Errorf("unexpected item: %s", v.name)
+			}
+			if v := AssertMaterializes[*item](t, m, "env"); v.name != "override env" {
+				t.Errorf("unexpected item: %s", v.name)
+			}
+			m.CloseAll()
+		})
+		if v := AssertMaterializes[*item](t, m); v.name != "primary" {
+			t.Errorf("factory should be restored: %s", v.name)
+		}
+		if v := AssertMaterializes[*item](t, m, "env"); v.name != "dev" {
+			t.Errorf("factory should be restored: %s", v.name)
+		}
+		m.CloseAll()
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	errs := run(func(t *fakeT) {
+		m := New(t)
+		AssertMaterializes[*item](t, m)
+	})
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "failed to materialize *materializetest.item tags:[]: not found factory") {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+
+	errs = run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() *item { return &item{} })
+		AssertNotFound[*item](t, m)
+	})
+	if len(errs) != 1 || errs[0] != "factory found for *materializetest.item tags:[]" {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+
+	errs = run(func(t *fakeT) {
+		m := New(t)
+		m.MustAdd(func() (*item, error) { return nil, errors.New("broken") })
+		AssertNotFound[*item](t, m)
+	})
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "unexpected error for *materializetest.item tags:[]: factory failed") {
+		t.Fatalf("unexpected failures: %+v", errs)
+	}
+}
@@ -0,0 +1,83 @@
+package materialize
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+)
+
+// setSource records the source location of the caller as where the factory
+// is registered. skip is the number of stack frames to skip, 0 identifies
+// the caller of setSource.
+func (f *Factory) setSource(skip int) {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return
+	}
+	f.File, f.Line = file, line
+}
+
+// Source returns a short form of the source location where the factory is
+// registered, like "file.go:12". It returns an empty string when unknown.
+func (f *Factory) Source() string {
+	if f.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line)
+}
+
+// Alias reports whether the factory serves an instance of another factory,
+// like one declared by Bind or a field of an output set. The instance is
+// closed by the other factory, not by the alias.
+func (f *Factory) Alias() bool {
+	return f.target != nil
+}
+
+// origin describes the factory with its name and source location for error
+// messages.
+func (f *Factory) origin() string {
+	src := f.Source()
+	switch {
+	case f.Name != "" && src != "":
+		return fmt.Sprintf("%q registered at %s", f.Name, src)
+	case f.Name != "":
+		return fmt.Sprintf("%q", f.Name)
+	case src != "":
+		return "registered at " + src
+	default:
+		return ""
+	}
+}
+
+// failed wraps an error of creation by the factory.
+func (f *Factory) failed(err error) error {
+	if o := f.origin(); o != "" {
+		return fmt.Errorf("factory failed (%s): %w", o, err)
+	}
+	return fmt.Errorf("factory failed: %w", err)
+}
+
+// add creates a Factory from a function, and adds it. skip is the number of
+// stack frames to skip to find where the factory is registered, 0
+// identifies the caller of add. setup is applied to the factory before
+// adding when it is not nil.
+func (m *Materializer) add(skip int, fn interface{}, tags []string, setup func(*Factory)) error {
+	f, err := newFactory(fn, tags)
+	if err != nil {
+		return err
+	}
+	f.setSource(skip + 1)
+	if setup != nil {
+		setup(f)
+	}
+	return m.AddFactory(f)
+}
+
+// AddNamed adds a function as Factory with a name and a description, which
+// are shown in error messages and introspection.
+func (m *Materializer) AddNamed(name, description string, fn interface{}, tags ...string) error {
+	return m.add(1, fn, tags, func(f *Factory) {
+		f.Name = name
+		f.Description = description
+	})
+}
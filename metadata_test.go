@@ -0,0 +1,106 @@
+package materialize
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sourceOf returns where a factory for the type of v with tags is
+// registered.
+func sourceOf(t *testing.T, m *Materializer, v interface{}, tags ...string) string {
+	t.Helper()
+	f, ok := m.Repository().Query(reflect.TypeOf(v), tags)
+	if !ok {
+		t.Fatalf("no factories for %T tags:%+v", v, tags)
+	}
+	return f.Source()
+}
+
+// thisLine returns a line number of the caller.
+func thisLine() int {
+	_, _, line, _ := runtime.Caller(1)
+	return line
+}
+
+func TestMetadata_Source(t *testing.T) {
+	m := newTestMaterializer(t)
+	line := thisLine() + 1
+	m.MustAdd(newFoo)
+	f, ok := m.Repository().Query(reflect.TypeOf((*Foo)(nil)), nil)
+	if !ok {
+		t.Fatal("factory not found")
+	}
+	if !strings.HasSuffix(f.File, "metadata_test.go") || f.Line != line {
+		t.Errorf("unexpected location: %s:%d", f.File, f.Line)
+	}
+	if s := f.Source(); s != "metadata_test.go:"+strconv.Itoa(line) {
+		t.Errorf("unexpected source: %s", s)
+	}
+
+	// variants of adding functions record their callers.
+	line = thisLine() + 1
+	if err := m.AddPrimary(newBar); err != nil {
+		t.Fatal(err)
+	}
+	if s := sourceOf(t, m, (*Bar)(nil)); s != "metadata_test.go:"+strconv.Itoa(line) {
+		t.Errorf("unexpected source of AddPrimary: %s", s)
+	}
+	line = thisLine() + 1
+	f, err := NewFactory(newFooBar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := f.Source(); s != "metadata_test.go:"+strconv.Itoa(line) {
+		t.Errorf("unexpected source of NewFactory: %s", s)
+	}
+}
+
+func TestMetadata_Named(t *testing.T) {
+	m := newTestMaterializer(t)
+	err := m.AddNamed("bar", "Bar for tests", func() (*Bar, error) {
+		return nil, errors.New("broken")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := m.Repository().Query(reflect.TypeOf((*Bar)(nil)), nil)
+	if f.Name != "bar" || f.Description != "Bar for tests" {
+		t.Errorf("unexpected metadata: %+v", f)
+	}
+
+	var bar *Bar
+	err = m.Materialize(&bar)
+	want := `factory failed ("bar" registered at ` + f.Source() + `): factory for *materialize.Bar failed: broken`
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	st := m.Stats()
+	if len(st.Factories) != 1 || st.Factories[0].Name != "bar" || st.Factories[0].Source != f.Source() {
+		t.Errorf("unexpected stats: %+v", st.Factories)
+	}
+}
+
+func TestMetadata_Duplicated(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	prev := sourceOf(t, m, (*Foo)(nil))
+	line := thisLine() + 1
+	err := m.AddNamed("foo2", "", newFoo)
+	want := `duplicated factory for type:*materialize.Foo tags:map[] ("foo2" registered at metadata_test.go:` + strconv.Itoa(line) + `, previously registered at ` + prev + `)`
+	if err == nil || err.Error() != want {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// factories without metadata don't describe origins.
+	r := &Repository{}
+	r.Add(&Factory{Type: reflect.TypeOf((*Foo)(nil))})
+	err = r.Add(&Factory{Type: reflect.TypeOf((*Foo)(nil))})
+	if err == nil || err.Error() != "duplicated factory for type:*materialize.Foo tags:map[]" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
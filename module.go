@@ -0,0 +1,102 @@
+package materialize
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Module bundles factories and sub-modules, which are installed to a
+// Materializer as a unit.
+type Module struct {
+	// Name is name of the module, which is used in error messages.
+	Name string
+
+	// Tags is default tags, which are added to all factories of the
+	// module. Those are not applied to sub-modules.
+	Tags []string
+
+	// Modules is sub-modules, which are installed with the module.
+	Modules []*Module
+
+	funcs []moduleFunc
+}
+
+type moduleFunc struct {
+	fn   interface{}
+	tags []string
+
+	// file and line are where the function is added.
+	file string
+	line int
+}
+
+// NewModule creates a new Module with default tags.
+func NewModule(name string, tags ...string) *Module {
+	return &Module{
+		Name: name,
+		Tags: tags,
+	}
+}
+
+// Add adds a function as Factory of the module.
+func (mod *Module) Add(fn interface{}, tags ...string) *Module {
+	mf := moduleFunc{fn: fn, tags: tags}
+	_, mf.file, mf.line, _ = runtime.Caller(1)
+	mod.funcs = append(mod.funcs, mf)
+	return mod
+}
+
+// Include adds sub-modules.
+func (mod *Module) Include(mods ...*Module) *Module {
+	mod.Modules = append(mod.Modules, mods...)
+	return mod
+}
+
+// factories creates factories of the module and its sub-modules, which are
+// not installed yet.
+func (mod *Module) factories(installed map[*Module]bool) ([]*Factory, error) {
+	if installed[mod] {
+		return nil, nil
+	}
+	installed[mod] = true
+	var facs []*Factory
+	for _, mf := range mod.funcs {
+		tags := append(append([]string{}, mod.Tags...), mf.tags...)
+		f, err := newFactory(mf.fn, tags)
+		if err != nil {
+			return nil, fmt.Errorf("module %q: %w", mod.Name, err)
+		}
+		f.module = mod.Name
+		f.File, f.Line = mf.file, mf.line
+		facs = append(facs, f)
+	}
+	for _, sub := range mod.Modules {
+		subFacs, err := sub.factories(installed)
+		if err != nil {
+			return nil, err
+		}
+		facs = append(facs, subFacs...)
+	}
+	return facs, nil
+}
+
+// Install installs factories of a module and its sub-modules. Modules which
+// have been installed already are skipped.
+func (m *Materializer) Install(mod *Module) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	installed := make(map[*Module]bool, len(m.installed))
+	for k := range m.installed {
+		installed[k] = true
+	}
+	facs, err := mod.factories(installed)
+	if err != nil {
+		return err
+	}
+	err = m.getRepo().addAll(facs)
+	if err != nil {
+		return err
+	}
+	m.installed = installed
+	return nil
+}
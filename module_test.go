@@ -0,0 +1,71 @@
+package materialize
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstall(t *testing.T) {
+	sub := NewModule("sub").Add(newBar)
+	mod := NewModule("main", "lib").
+		Add(newFoo).
+		Add(newFooBar, "extra").
+		Include(sub)
+
+	m := newTestMaterializer(t)
+	if err := m.Install(mod); err != nil {
+		t.Fatalf("failed to install: %s", err)
+	}
+	// install again is ignored.
+	if err := m.Install(mod); err != nil {
+		t.Fatalf("failed to install again: %s", err)
+	}
+	if err := m.Install(sub); err != nil {
+		t.Fatalf("failed to install sub-module again: %s", err)
+	}
+
+	var foo *Foo
+	if err := m.Materialize(&foo, "lib"); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+	var fooBar *FooBar
+	if err := m.Materialize(&fooBar, "lib", "extra"); err != nil {
+		t.Fatalf("failed to materialize *FooBar: %s", err)
+	}
+	var bar *Bar
+	if err := m.Materialize(&bar); err != nil {
+		t.Fatalf("failed to materialize *Bar: %s", err)
+	}
+	f, ok := m.getRepo().Query(reflect.TypeOf(bar), nil)
+	if !ok || len(f.Tags) != 0 {
+		t.Fatalf("default tags should not be applied to sub-modules: %+v", f)
+	}
+}
+
+func TestInstall_Conflict(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := m.Install(NewModule("first").Add(newFoo, "foo")); err != nil {
+		t.Fatalf("failed to install first: %s", err)
+	}
+	second := NewModule("second").Add(newBar).Add(newFoo, "foo")
+	err := m.Install(second)
+	if err == nil {
+		t.Fatal("install second should be failed")
+	}
+	if s := err.Error(); !strings.HasPrefix(s, `duplicated factory for type:*materialize.Foo tags:[foo]: module "second" conflicts with module "first" (registered at module_test.go:`) ||
+		!strings.HasSuffix(s, fmt.Sprintf(", previously registered at %s)", sourceOf(t, m, (*Foo)(nil), "foo"))) {
+		t.Fatalf("unexpected error: %s", s)
+	}
+	// factories of the failed module are rolled back.
+	var bar *Bar
+	if err := m.Materialize(&bar); err == nil {
+		t.Fatal("materialize *Bar should be failed")
+	}
+
+	err = m.Install(NewModule("broken").Add(123))
+	if err == nil || err.Error() != `module "broken": factory should be a function` {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Out is a marker to make a struct an output set. When a factory returns a
+// struct which embeds Out, each exported field of the struct is registered
+// as its own type with tags of the factory. Those share one invocation of
+// the factory and one cached instance of the struct.
+//
+// Additional tags of a field are given by "materialize" struct tag, like
+// `materialize:"primary,rw"`. A field with `materialize:"-"` is ignored.
+//
+// Closing the struct closes its fields which implement Close() method, in
+// reverse order of fields.
+type Out struct{}
+
+var outType = reflect.TypeOf(Out{})
+
+// isOutSet checks whether the type is a struct which embeds Out.
+func isOutSet(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if sf.Anonymous && sf.Type == outType {
+			return true
+		}
+	}
+	return false
+}
+
+// outFields returns indexes of fields which are registered for an output
+// set.
+func outFields(typ reflect.Type) []int {
+	var idx []int
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		if !sf.IsExported() || sf.Type == outType || sf.Tag.Get("materialize") == "-" {
+			continue
+		}
+		idx = append(idx, i)
+	}
+	return idx
+}
+
+// outFactories creates factories for fields of an output set, which the
+// factory of the set returns.
+func outFactories(set *Factory) []*Factory {
+	var facs []*Factory
+	for _, i := range outFields(set.Type) {
+		i, sf := i, set.Type.Field(i)
+		tags := set.Tags.list()
+		if s := sf.Tag.Get("materialize"); s != "" {
+			tags = append(tags, strings.Split(s, ",")...)
+		}
+		facs = append(facs, &Factory{
+			Type: sf.Type,
+			Func: func(x *Context) (reflect.Value, error) {
+				rv := reflect.New(set.Type)
+				err := x.m.materializeFactory(x, rv, set)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v := rv.Elem().Field(i)
+				if isNil(v) {
+					return reflect.Value{}, fmt.Errorf("factory for %s returned nil at field %s", set.Type, sf.Name)
+				}
+				return v, nil
+			},
+			Tags:        newTags(tags),
+			Name:        set.Name,
+			Description: fmt.Sprintf("field %s of %s", sf.Name, set.Type),
+			File:        set.File,
+			Line:        set.Line,
+			Priority:    set.Priority,
+			Cond:        set.Cond,
+			module:      set.module,
+			target: func(*Materializer) (*Factory, error) {
+				return set, nil
+			},
+		})
+	}
+	return facs
+}
+
+// outCloseFunc returns a function to close fields of an output set in
+// reverse order, or nil when no fields can be closed.
+func outCloseFunc(v reflect.Value) func() error {
+	var fns []func() error
+	for _, i := range outFields(v.Type()) {
+		if isNil(v.Field(i)) {
+			continue
+		}
+		if fn := toCloseFunc(v.Field(i), nil); fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) == 0 {
+		return nil
+	}
+	return func() error {
+		var errs []error
+		for i := len(fns) - 1; i >= 0; i-- {
+			errs = append(errs, fns[i]())
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// outCheckFunc returns a function to check health of fields of an output
+// set, or nil when no fields can be checked.
+func outCheckFunc(v reflect.Value) func(context.Context) error {
+	var fns []func(context.Context) error
+	for _, i := range outFields(v.Type()) {
+		if isNil(v.Field(i)) {
+			continue
+		}
+		if fn := toCheckFunc(v.Field(i)); fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) == 0 {
+		return nil
+	}
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, fn := range fns {
+			errs = append(errs, fn(ctx))
+		}
+		return errors.Join(errs...)
+	}
+}
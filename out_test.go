@@ -0,0 +1,176 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type outPool struct {
+	sink *[]string
+}
+
+func (p *outPool) Close() {
+	*p.sink = append(*p.sink, "pool")
+}
+
+type outMetrics struct {
+	sink *[]string
+}
+
+func (m *outMetrics) Close() error {
+	*m.sink = append(*m.sink, "metrics")
+	return nil
+}
+
+func (m *outMetrics) Check(context.Context) error {
+	return errors.New("unhealthy")
+}
+
+type outClient struct {
+	Out
+
+	Pool    *outPool
+	Metrics *outMetrics `materialize:"metrics"`
+	Ignored *Foo        `materialize:"-"`
+	hidden  *Bar
+}
+
+func TestOut(t *testing.T) {
+	m := newTestMaterializer(t)
+	var (
+		n    int
+		sink []string
+	)
+	err := m.Add(func() (outClient, func()) {
+		n++
+		return outClient{
+			Pool:    &outPool{sink: &sink},
+			Metrics: &outMetrics{sink: &sink},
+			hidden:  &Bar{},
+		}, func() {
+			sink = append(sink, "cleanup")
+		}
+	}, "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		pool    *outPool
+		metrics *outMetrics
+		set     outClient
+	)
+	x := &Context{m: m}
+	x.Materialize(&pool, "client").Materialize(&metrics, "client", "metrics").Materialize(&set, "client")
+	if err := x.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("factory should be called once: %d", n)
+	}
+	if pool != set.Pool || metrics != set.Metrics {
+		t.Error("fields should be shared")
+	}
+	var foo *Foo
+	if err := m.Materialize(&foo); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("ignored field should not be registered: %v", err)
+	}
+	var bar *Bar
+	if err := m.Materialize(&bar); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("unexported field should not be registered: %v", err)
+	}
+
+	hs := m.Health(context.Background())
+	if len(hs) != 1 || hs[0].Type != "materialize.outClient" || hs[0].Error != "unhealthy" {
+		t.Errorf("unexpected health: %+v", hs)
+	}
+
+	m.CloseAll()
+	if got := len(sink); got != 3 || sink[0] != "metrics" || sink[1] != "pool" || sink[2] != "cleanup" {
+		t.Errorf("unexpected close: %+v", sink)
+	}
+}
+
+func TestOut_Conflict(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *outPool { return &outPool{} }, "client")
+	err := m.Add(func() outClient { return outClient{} }, "client")
+	if err == nil {
+		t.Fatal("adding output set should be failed")
+	}
+	// the set and its fields are rolled back.
+	var set outClient
+	if err := m.Materialize(&set, "client"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var metrics *outMetrics
+	if err := m.Materialize(&metrics, "client", "metrics"); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOut_NilField(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() outClient { return outClient{Pool: &outPool{}} })
+	var metrics *outMetrics
+	err := m.Materialize(&metrics, "metrics")
+	if err == nil || !strings.HasSuffix(err.Error(), "factory for materialize.outClient returned nil at field Metrics") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestOut_Evict(t *testing.T) {
+	m := newTestMaterializer(t)
+	var (
+		n    int
+		sink []string
+	)
+	m.MustAdd(func() outClient {
+		n++
+		return outClient{Pool: &outPool{sink: &sink}, Metrics: &outMetrics{sink: &sink}}
+	})
+	var pool *outPool
+	if err := m.Materialize(&pool); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Evict(reflect.TypeOf(pool)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink) != 2 {
+		t.Errorf("output set should be closed: %+v", sink)
+	}
+	var metrics *outMetrics
+	if err := m.Materialize(&metrics, "metrics"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("output set should be created again: %d", n)
+	}
+}
+
+func TestOut_Fork(t *testing.T) {
+	m := newTestMaterializer(t)
+	f, err := NewFactory(func() outClient { return outClient{Pool: &outPool{}} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddFactory(f); err != nil {
+		t.Fatal(err)
+	}
+	m2 := m.Fork()
+	r, r2 := m.Repository(), m2.Repository()
+	r2.Remove(f)
+	if err := r2.Add(f); err != nil {
+		t.Fatal(err)
+	}
+	r.Remove(f)
+	if _, ok := r.Query(reflect.TypeOf((*outPool)(nil)), nil); ok {
+		t.Error("fields should be removed with the set")
+	}
+	if _, ok := r2.Query(reflect.TypeOf((*outPool)(nil)), nil); !ok {
+		t.Error("fields in the fork should not be removed")
+	}
+}
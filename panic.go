@@ -0,0 +1,78 @@
+package materialize
+
+import (
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicError is an error which recovered from a panic in a factory.
+type PanicError struct {
+	// Value is a value which passed to panic.
+	Value interface{}
+
+	// Stack is a stack trace of the goroutine where the panic happened.
+	Stack []byte
+
+	Type reflect.Type
+	Tags []string
+
+	// Path is types of factories in materialization, from the root to the
+	// panicked one.
+	Path []string
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("factory for %s tags:%+v panicked: %v (path: %s)", e.Type, e.Tags, e.Value, strings.Join(e.Path, " -> "))
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// WithPanicRecovery enables or disables recovery from panics in factories.
+// When enabled, a panic in a factory makes it fail with PanicError instead
+// of crashing the process.
+func (m *Materializer) WithPanicRecovery(enabled bool) *Materializer {
+	m.recoverPanic = enabled
+	return m
+}
+
+// callFuncOnce calls the factory function of x once, and recovers a panic in
+// it when enabled.
+func callFuncOnce(x *Context) (v reflect.Value, err error) {
+	// wait for goroutines which the factory started by Go and left.
+	defer x.wg.Wait()
+	if !x.m.recoverPanic {
+		return x.f.Func(x)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			x.runCleanup()
+			v, err = reflect.Value{}, newPanicError(x, r)
+		}
+	}()
+	return x.f.Func(x)
+}
+
+func newPanicError(x *Context, r interface{}) *PanicError {
+	var path []string
+	for y := x; y != nil && y.f != nil; y = y.p {
+		path = append(path, y.f.Type.String())
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return &PanicError{
+		Value: r,
+		Stack: debug.Stack(),
+		Type:  x.f.Type,
+		Tags:  x.f.Tags.list(),
+		Path:  path,
+	}
+}
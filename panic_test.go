@@ -0,0 +1,70 @@
+package materialize
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	m := newTestMaterializer(t).WithPanicRecovery(true)
+	errBroken := errors.New("broken")
+	m.MustAdd(func(x *Context) (*Foo, error) {
+		var bar *Bar
+		if err := x.Materialize(&bar).Error(); err != nil {
+			return nil, err
+		}
+		return &Foo{}, nil
+	})
+	m.MustAdd(func() *Bar {
+		panic(errBroken)
+	}, "b")
+
+	for i := 0; i < 2; i++ {
+		var foo *Foo
+		err := m.Materialize(&foo)
+		var pe *PanicError
+		if !errors.As(err, &pe) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pe.Type != reflect.TypeOf((*Bar)(nil)) || !reflect.DeepEqual(pe.Tags, []string{"b"}) || pe.Value != errBroken {
+			t.Errorf("unexpected panic error: %+v", pe)
+		}
+		if !reflect.DeepEqual(pe.Path, []string{"*materialize.Foo", "*materialize.Bar"}) {
+			t.Errorf("unexpected path: %+v", pe.Path)
+		}
+		if !strings.Contains(string(pe.Stack), "panic_test.go") {
+			t.Errorf("stack should contain the factory:\n%s", pe.Stack)
+		}
+		if !errors.Is(err, errBroken) {
+			t.Errorf("error should wrap the panic value: %v", err)
+		}
+		if s := pe.Error(); s != "factory for *materialize.Bar tags:[b] panicked: broken (path: *materialize.Foo -> *materialize.Bar)" {
+			t.Errorf("unexpected message: %s", s)
+		}
+	}
+}
+
+func TestPanic_CacheConsistency(t *testing.T) {
+	m := newTestMaterializer(t)
+	var n int
+	m.MustAdd(func() *Foo {
+		n++
+		panic("boom")
+	})
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() {
+				if r := recover(); r != "boom" {
+					t.Errorf("unexpected recovered value: %v", r)
+				}
+			}()
+			var foo *Foo
+			m.Materialize(&foo)
+		}()
+	}
+	if n != 2 {
+		t.Errorf("factory should be called again: %d", n)
+	}
+}
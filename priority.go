@@ -0,0 +1,34 @@
+package materialize
+
+// Priorities of factories.
+const (
+	// PriorityDefault is a priority for fallback factories, which are used
+	// only if no other factories match.
+	PriorityDefault = -1
+
+	// PriorityNormal is a priority for factories added by Add.
+	PriorityNormal = 0
+
+	// PriorityPrimary is a priority for factories which win ties.
+	PriorityPrimary = 1
+)
+
+// AddDefault adds a function as fallback Factory, which is used only if no
+// other factories match.
+func (m *Materializer) AddDefault(fn interface{}, tags ...string) error {
+	return m.addWithPriority(PriorityDefault, fn, tags)
+}
+
+// AddPrimary adds a function as primary Factory, which wins other factories
+// with same score.
+func (m *Materializer) AddPrimary(fn interface{}, tags ...string) error {
+	return m.addWithPriority(PriorityPrimary, fn, tags)
+}
+
+// addWithPriority adds a function as Factory with a priority. The source
+// location is recorded for the caller of its caller.
+func (m *Materializer) addWithPriority(priority int, fn interface{}, tags []string) error {
+	return m.add(2, fn, tags, func(f *Factory) {
+		f.Priority = priority
+	})
+}
@@ -0,0 +1,89 @@
+package materialize
+
+import "testing"
+
+func TestAddDefault(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := m.AddDefault(newFooBar); err != nil {
+		t.Fatal(err)
+	}
+	var f Fooer
+	if err := m.Materialize(&f); err != nil {
+		t.Fatalf("failed to materialize Fooer: %s", err)
+	}
+	if _, ok := f.(*FooBar); !ok {
+		t.Fatalf("not *FooBar: %T", f)
+	}
+
+	// a fallback factory is used only if no other factories match.
+	m = newTestMaterializer(t)
+	if err := m.AddDefault(newFooBar); err != nil {
+		t.Fatal(err)
+	}
+	m.MustAdd(newFoo, "extra")
+	if err := m.Materialize(&f); err != nil {
+		t.Fatalf("failed to materialize Fooer: %s", err)
+	}
+	if _, ok := f.(*Foo); !ok {
+		t.Fatalf("not *Foo: %T", f)
+	}
+}
+
+func TestAddDefault_SameTags(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := m.AddDefault(newStringFactory("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(newStringFactory("normal")); err != nil {
+		t.Fatalf("normal factory should not conflict with default: %s", err)
+	}
+	if err := m.AddDefault(newStringFactory("default2")); err == nil {
+		t.Fatal("default factories should conflict")
+	}
+	var s string
+	if err := m.Materialize(&s); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	if s != "normal" {
+		t.Fatalf("unexpected string: %q", s)
+	}
+}
+
+type upperCont string
+
+func (g upperCont) Get() string {
+	return string(g) + "!"
+}
+
+func TestAddPrimary(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		m := newTestMaterializer(t)
+		m.MustAdd(func() strCont { return "normal" })
+		if err := m.AddPrimary(func() upperCont { return "primary" }); err != nil {
+			t.Fatal(err)
+		}
+		var g Getter
+		if err := m.Materialize(&g); err != nil {
+			t.Fatalf("failed to materialize Getter: %s", err)
+		}
+		if s := g.Get(); s != "primary!" {
+			t.Fatalf("unexpected Getter: %q", s)
+		}
+	}
+}
+
+func TestAddPrimary_Score(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func() strCont { return "normal" }, "foo")
+	if err := m.AddPrimary(func() upperCont { return "primary" }); err != nil {
+		t.Fatal(err)
+	}
+	// score wins priority.
+	var g Getter
+	if err := m.Materialize(&g, "foo"); err != nil {
+		t.Fatalf("failed to materialize Getter: %s", err)
+	}
+	if s := g.Get(); s != "normal" {
+		t.Fatalf("unexpected Getter: %q", s)
+	}
+}
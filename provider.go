@@ -0,0 +1,55 @@
+package materialize
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+// AddProvider adds exported methods of a provider which take *Context as 1st
+// argument, as factories with tags. For example:
+//
+//	func (p *Providers) DB(x *materialize.Context) (*sql.DB, error)
+//
+// Other methods, like accessors and Close, are not added. Methods which
+// return error as 1st value are not added either. Factories are named after
+// the type of the provider and the method. This fails when one of methods
+// isn't accepted by Add, or when no methods are added.
+func (m *Materializer) AddProvider(p interface{}, tags ...string) error {
+	rv := reflect.ValueOf(p)
+	if !rv.IsValid() {
+		return fmt.Errorf("provider should not be nil")
+	}
+	_, file, line, _ := runtime.Caller(1)
+	typ := rv.Type()
+	var facs []*Factory
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		fn := rv.Method(i)
+		if !isProviderMethod(fn.Type()) {
+			continue
+		}
+		f, err := newFactory(fn.Interface(), tags)
+		if err != nil {
+			return fmt.Errorf("invalid factory method %s.%s: %w", typ, name, err)
+		}
+		f.Name = fmt.Sprintf("%s.%s", typ, name)
+		f.File, f.Line = file, line
+		facs = append(facs, f)
+	}
+	if len(facs) == 0 {
+		return fmt.Errorf("no factory methods in %s", typ)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getRepo().addAll(facs)
+}
+
+// isProviderMethod checks whether a method is a factory method.
+func isProviderMethod(ft reflect.Type) bool {
+	if ft.NumIn() == 0 || ft.In(0) != ctxType {
+		return false
+	}
+	return ft.NumOut() > 0 && ft.Out(0) != errType
+}
@@ -0,0 +1,107 @@
+package materialize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testProviders struct {
+	fooID int
+}
+
+func (p *testProviders) Foo(*Context) *Foo {
+	return &Foo{id: p.fooID}
+}
+
+func (p *testProviders) Bar(x *Context) (*Bar, error) {
+	var foo *Foo
+	if err := x.Materialize(&foo).Error(); err != nil {
+		return nil, err
+	}
+	return &Bar{}, nil
+}
+
+// Helper is not a factory, it has an argument other than *Context.
+func (p *testProviders) Helper(s string) *FooX {
+	return &FooX{}
+}
+
+// Validate is not a factory, it returns error only.
+func (p *testProviders) Validate(*Context) error {
+	return nil
+}
+
+// Name and Count are not factories, those are accessors.
+func (p *testProviders) Name() string {
+	return "providers"
+}
+
+func (p *testProviders) Count() int {
+	return 2
+}
+
+type invalidProviders struct{}
+
+func (invalidProviders) Foo(*Context) (*Foo, *Bar, *FooX, error) {
+	return nil, nil, nil, nil
+}
+
+func TestAddProvider(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := m.AddProvider(&testProviders{fooID: 42}, "prov"); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		foo *Foo
+		bar *Bar
+	)
+	x := &Context{m: m}
+	if err := x.Materialize(&foo, "prov").Materialize(&bar, "prov").Error(); err != nil {
+		t.Fatal(err)
+	}
+	if foo.id != 42 {
+		t.Errorf("unexpected Foo: %+v", foo)
+	}
+
+	var (
+		fx  *FooX
+		err error
+		s   string
+		n   int
+	)
+	for _, rcv := range []interface{}{&fx, &err, &s, &n} {
+		if err := m.Materialize(rcv); !errors.Is(err, ErrorNotFound) {
+			t.Errorf("non-factory method is added for %T: %v", rcv, err)
+		}
+	}
+
+	st := m.Snapshot()
+	if len(st.Factories) != 2 || st.Factories[0].Name != "*materialize.testProviders.Bar" || !strings.HasPrefix(st.Factories[0].Source, "provider_test.go:") {
+		t.Errorf("unexpected factories: %+v", st.Factories)
+	}
+}
+
+func TestAddProvider_Error(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := m.AddProvider(nil); err == nil || err.Error() != "provider should not be nil" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := m.AddProvider(&Foo{}); err == nil || err.Error() != "no factory methods in *materialize.Foo" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := m.AddProvider(invalidProviders{}); !errors.Is(err, ErrorFactoryRetun) || !strings.HasPrefix(err.Error(), "invalid factory method materialize.invalidProviders.Foo: ") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// conflicted providers are rolled back.
+	m.MustAdd(newBar)
+	if err := m.AddProvider(&testProviders{}); err == nil {
+		t.Fatal("conflicted provider should be failed")
+	}
+	var foo *Foo
+	if err := m.Materialize(&foo); !errors.Is(err, ErrorNotFound) {
+		t.Errorf("factories should be rolled back: %v", err)
+	}
+}
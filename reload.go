@@ -0,0 +1,98 @@
+package materialize
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+)
+
+// Reloadable is a handle of an instance which can be replaced by
+// Materializer.Reload. Dependents should keep the handle and Load the
+// instance each time instead of keeping the instance itself.
+type Reloadable[T any] struct {
+	p atomic.Pointer[T]
+}
+
+// Load returns the current instance.
+func (r *Reloadable[T]) Load() T {
+	return *r.p.Load()
+}
+
+func (r *Reloadable[T]) storeValue(v reflect.Value) {
+	t := v.Interface().(T)
+	r.p.Store(&t)
+}
+
+type valueStorer interface {
+	storeValue(reflect.Value)
+}
+
+// AddReloadable adds a function as reloadable Factory of T. Both T and
+// *Reloadable[T] can be materialized with tags.
+func AddReloadable[T any](m *Materializer, fn interface{}, tags ...string) error {
+	f, err := newFactory(fn, tags)
+	if err != nil {
+		return err
+	}
+	f.setSource(1)
+	if typ := reflect.TypeOf((*T)(nil)).Elem(); f.Type != typ {
+		return fmt.Errorf("factory should return %s but %s", typ, f.Type)
+	}
+	f.handle = &Factory{
+		Type: reflect.TypeOf((*Reloadable[T])(nil)),
+		Func: func(x *Context) (reflect.Value, error) {
+			var v T
+			err := x.m.materializeFactory(x, reflect.ValueOf(&v), f)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			r := &Reloadable[T]{}
+			r.p.Store(&v)
+			return reflect.ValueOf(r), nil
+		},
+		Tags: f.Tags,
+		File: f.File,
+		Line: f.Line,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getRepo().addAll([]*Factory{f, f.handle})
+}
+
+// Reload creates a new instance for a reloadable factory of the type with
+// tags, and replaces the cached instance with it. *Reloadable[T] handles
+// switch over to the new instance, other dependents of the old instance are
+// evicted, then the old instance is closed. For a type which is declared by
+// Bind, the instance which serves it is reloaded.
+func (m *Materializer) Reload(typ reflect.Type, queryTags ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := m.queryTarget(typ, queryTags)
+	if err != nil {
+		return err
+	}
+	if f.handle == nil {
+		return fmt.Errorf("%w: type:%s tags:%+v", ErrorNotReloadable, typ, queryTags)
+	}
+	if _, ok := m.cache.getObj(f); !ok {
+		// nothing to reload, it will be created at next materialization.
+		return nil
+	}
+	cx := (&Context{m: m, ctx: context.Background()}).child(f)
+	v, err := m.create(cx)
+	if err != nil {
+		return f.failed(err)
+	}
+	old, removed := m.cache.replace(cx, v, func(d *Factory) bool {
+		return d == f.handle
+	})
+	if hv, ok := m.cache.getObj(f.handle); ok {
+		hv.Interface().(valueStorer).storeValue(v)
+	}
+	closeEntries(removed, m.closed)
+	if old != nil {
+		old.closeValue(m.closed)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package materialize
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+type reloadConfig struct {
+	Res0
+	version int
+}
+
+func TestReload(t *testing.T) {
+	var sink []string
+	n := 0
+	m := newTestMaterializer(t)
+	err := AddReloadable[*reloadConfig](m, func() *reloadConfig {
+		n++
+		return &reloadConfig{Res0: Res0{&sink, fmt.Sprintf("config%d", n)}, version: n}
+	})
+	if err != nil {
+		t.Fatalf("failed to add reloadable: %s", err)
+	}
+	// a dependent of *Reloadable[*reloadConfig]
+	m.MustAdd(func(x *Context) *FooX {
+		var h *Reloadable[*reloadConfig]
+		x.Materialize(&h)
+		return &FooX{foo: &Foo{id: h.Load().version}}
+	})
+	// a dependent of *reloadConfig
+	m.MustAdd(func(x *Context) *Res1 {
+		var c *reloadConfig
+		x.Materialize(&c)
+		return &Res1{&sink, fmt.Sprintf("user%d", c.version), ""}
+	})
+
+	var h *Reloadable[*reloadConfig]
+	if err := m.Materialize(&h); err != nil {
+		t.Fatalf("failed to materialize handle: %s", err)
+	}
+	var fooX *FooX
+	if err := m.Materialize(&fooX); err != nil {
+		t.Fatalf("failed to materialize FooX: %s", err)
+	}
+	var r1 *Res1
+	if err := m.Materialize(&r1); err != nil {
+		t.Fatalf("failed to materialize Res1: %s", err)
+	}
+	if v := h.Load().version; v != 1 {
+		t.Fatalf("unexpected version: %d", v)
+	}
+
+	if err := m.Reload(reflect.TypeOf((*reloadConfig)(nil))); err != nil {
+		t.Fatalf("failed to reload: %s", err)
+	}
+	if v := h.Load().version; v != 2 {
+		t.Fatalf("unexpected version after reload: %d", v)
+	}
+	if s := strings.Join(sink, ","); s != "user1,config1" {
+		t.Fatalf("unexpected sink after reload: %s", s)
+	}
+
+	// dependent of the handle is kept.
+	var fooX2 *FooX
+	if err := m.Materialize(&fooX2); err != nil {
+		t.Fatalf("failed to materialize FooX: %s", err)
+	}
+	if fooX2 != fooX {
+		t.Fatal("FooX should be kept")
+	}
+	// dependent of the instance is created again.
+	var r1b *Res1
+	if err := m.Materialize(&r1b); err != nil {
+		t.Fatalf("failed to materialize Res1: %s", err)
+	}
+	if r1b.id != "user2" {
+		t.Fatalf("unexpected Res1: %s", r1b.id)
+	}
+	var c *reloadConfig
+	if err := m.Materialize(&c); err != nil {
+		t.Fatalf("failed to materialize config: %s", err)
+	}
+	if c != h.Load() {
+		t.Fatal("config should be same with handle")
+	}
+
+	sink = nil
+	m.CloseAll()
+	if s := strings.Join(sink, ","); s != "user2,config2" {
+		t.Fatalf("unexpected sink after close all: %s", s)
+	}
+}
+
+func TestReload_Error(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	err := m.Reload(reflect.TypeOf((*Foo)(nil)))
+	if !errors.Is(err, ErrorNotReloadable) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = AddReloadable[*Bar](m, newFoo)
+	if err == nil || err.Error() != "factory should return *materialize.Bar but *materialize.Foo" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReload_ConcurrentAdd(t *testing.T) {
+	m := newTestMaterializer(t)
+	if err := AddReloadable[*Foo](m, newFoo); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			m.MustAdd(newFoo, fmt.Sprintf("tag%d", i))
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		runtime.Gosched()
+		if err := m.Reload(reflect.TypeOf((*Foo)(nil))); err != nil {
+			t.Fatalf("failed to reload: %s", err)
+		}
+	}
+	<-done
+}
@@ -2,53 +2,240 @@ package materialize
 
 import (
 	"reflect"
+	"sort"
+	"sync"
 )
 
-// Repository stores factories for each types.
+// Repository stores factories for each types. It is safe for concurrent use.
 type Repository struct {
+	mu  sync.RWMutex
 	fss map[reflect.Type]factorySet
+
+	// shared is types whose factory sets are shared with forked
+	// repositories. Those are copied before modification.
+	shared map[reflect.Type]bool
+
+	// outs is factories for fields of output sets in this repository,
+	// which are added and removed with the factory of the set.
+	outs map[*Factory][]*Factory
+
+	// order is registration order of factories, which breaks ties of
+	// queries. seq is the next order.
+	order map[*Factory]uint64
+	seq   uint64
 }
 
 // Add adds a factory for a type with tags.
 func (r *Repository) Add(f *Factory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.add(f)
+}
+
+func (r *Repository) add(f *Factory) error {
+	err := r.factorySet(f.Type).add(f)
+	if err != nil {
+		return err
+	}
+	if r.order == nil {
+		r.order = map[*Factory]uint64{}
+	}
+	r.order[f] = r.seq
+	r.seq++
+	if !isOutSet(f.Type) {
+		return nil
+	}
+	outs := outFactories(f)
+	err = r.addEach(outs)
+	if err != nil {
+		r.remove(f)
+		return err
+	}
+	if r.outs == nil {
+		r.outs = map[*Factory][]*Factory{}
+	}
+	r.outs[f] = outs
+	return nil
+}
+
+// addAll adds factories. When adding one of them failed, factories added
+// already are removed.
+func (r *Repository) addAll(facs []*Factory) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.addEach(facs)
+}
+
+// addEach is addAll without locking.
+func (r *Repository) addEach(facs []*Factory) error {
+	for i, f := range facs {
+		err := r.add(f)
+		if err != nil {
+			for _, g := range facs[:i] {
+				r.remove(g)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// factorySet gets or creates a factory set for the type to modify.
+func (r *Repository) factorySet(typ reflect.Type) factorySet {
 	if r.fss == nil {
 		r.fss = map[reflect.Type]factorySet{}
 	}
-	fs, ok := r.fss[f.Type]
+	fs, ok := r.fss[typ]
 	if !ok {
 		fs = factorySet{}
-		r.fss[f.Type] = fs
+		r.fss[typ] = fs
+	}
+	if r.shared[typ] {
+		fs = fs.clone()
+		r.fss[typ] = fs
+		delete(r.shared, typ)
+	}
+	return fs
+}
+
+// fork creates a new Repository which has same factories. Factory sets are
+// shared until modified.
+func (r *Repository) fork() *Repository {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r2 := &Repository{
+		fss:    make(map[reflect.Type]factorySet, len(r.fss)),
+		shared: make(map[reflect.Type]bool, len(r.fss)),
+		outs:   make(map[*Factory][]*Factory, len(r.outs)),
+		order:  make(map[*Factory]uint64, len(r.order)),
+		seq:    r.seq,
+	}
+	for f, outs := range r.outs {
+		r2.outs[f] = outs
+	}
+	for f, n := range r.order {
+		r2.order[f] = n
 	}
-	err := fs.add(f)
+	if r.shared == nil {
+		r.shared = map[reflect.Type]bool{}
+	}
+	for typ, fs := range r.fss {
+		r2.fss[typ] = fs
+		r2.shared[typ] = true
+		r.shared[typ] = true
+	}
+	return r2
+}
+
+// Replace adds a factory, and removes factories which have same type and
+// tags regardless of their priorities and conditions. The removed factories
+// are returned. Those are restored when adding the factory failed.
+func (r *Repository) Replace(f *Factory) ([]*Factory, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var prevs []*Factory
+	k := f.Tags.joinKeys()
+	for _, g := range r.fss[f.Type] {
+		if g.Tags.joinKeys() == k {
+			prevs = append(prevs, g)
+		}
+	}
+	for _, g := range prevs {
+		r.remove(g)
+	}
+	err := r.add(f)
 	if err != nil {
-		return err
+		for _, g := range prevs {
+			r.add(g)
+		}
+		return nil, err
+	}
+	return prevs, nil
+}
+
+// Remove removes a factory.
+func (r *Repository) Remove(f *Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remove(f)
+}
+
+func (r *Repository) remove(f *Factory) {
+	k := f.key()
+	if fs, ok := r.fss[f.Type]; !ok || fs[k] != f {
+		return
+	}
+	delete(r.factorySet(f.Type), k)
+	outs := r.outs[f]
+	delete(r.outs, f)
+	delete(r.order, f)
+	for _, g := range outs {
+		r.remove(g)
 	}
-	return nil
 }
 
-// Query queries a factory for type.
+// Query queries a factory for type. Conditions of factories are evaluated
+// with nil Materializer.
 func (r *Repository) Query(typ reflect.Type, queryTags []string) (*Factory, bool) {
-	tags := newTags(queryTags)
-	mf := r.findDirect(typ, tags)
-	if typ.Kind() == reflect.Interface {
+	mf := r.query(nil, typ, newTags(queryTags))
+	if mf == nil {
+		return nil, false
+	}
+	return mf.fac, true
+}
+
+// query queries the best matched factory for type, which is available for
+// the Materializer. For an interface, factories of its implementations are
+// queried too unless the Materializer has strict binding.
+func (r *Repository) query(m *Materializer, typ reflect.Type, tags Tags) *matchedFactory {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mf := r.findDirect(m, typ, tags)
+	if typ.Kind() == reflect.Interface && (m == nil || !m.strict) {
 		for t, fs := range r.fss {
 			if t != typ && !t.AssignableTo(typ) {
 				continue
 			}
-			mf = fs.find(mf, tags)
+			mf = fs.find(m, mf, tags, r.order)
 		}
 	}
-	if mf == nil {
-		return nil, false
-	}
-	return mf.fac, true
+	return mf
+}
+
+// selects checks whether the factory is selected when its type is queried
+// with its tags directly.
+func (r *Repository) selects(m *Materializer, f *Factory) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mf := r.findDirect(m, f.Type, f.Tags)
+	return mf != nil && mf.fac == f
 }
 
 // findDirect find a factory set for the type.
-func (r *Repository) findDirect(typ reflect.Type, tags Tags) *matchedFactory {
+func (r *Repository) findDirect(m *Materializer, typ reflect.Type, tags Tags) *matchedFactory {
 	fs, ok := r.fss[typ]
 	if !ok {
 		return nil
 	}
-	return fs.find(nil, tags)
+	return fs.find(m, nil, tags, r.order)
+}
+
+// factories returns all factories ordered by type and tags.
+func (r *Repository) factories() []*Factory {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var facs []*Factory
+	for _, fs := range r.fss {
+		for _, f := range fs {
+			facs = append(facs, f)
+		}
+	}
+	sort.Slice(facs, func(i, j int) bool {
+		a, b := facs[i], facs[j]
+		if a.Type != b.Type {
+			return a.Type.String() < b.Type.String()
+		}
+		return a.Tags.joinKeys() < b.Tags.joinKeys()
+	})
+	return facs
 }
@@ -0,0 +1,145 @@
+package materialize
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Default values of RetryPolicy.
+const (
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+	DefaultRetryMultiplier     = 2.0
+)
+
+// RetryPolicy is a policy to retry a failed factory.
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts, including the first one.
+	// The factory is not retried when it is less than 2.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry.
+	// DefaultRetryInitialBackoff is used when it is zero.
+	InitialBackoff time.Duration
+
+	// MaxBackoff limits the wait before each retry.
+	// DefaultRetryMaxBackoff is used when it is zero.
+	MaxBackoff time.Duration
+
+	// Multiplier is a factor to increase the wait for each retry.
+	// DefaultRetryMultiplier is used when it is zero.
+	Multiplier float64
+
+	// Jitter is a fraction in [0, 1] to shorten the wait randomly.
+	Jitter float64
+
+	// Retryable reports whether an error should be retried. All errors are
+	// retried when it is nil.
+	Retryable func(error) bool
+}
+
+// backoff returns the wait after n-th attempt.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialBackoff)
+	if d == 0 {
+		d = float64(DefaultRetryInitialBackoff)
+	}
+	mul := p.Multiplier
+	if mul == 0 {
+		mul = DefaultRetryMultiplier
+	}
+	max := float64(p.MaxBackoff)
+	if max == 0 {
+		max = float64(DefaultRetryMaxBackoff)
+	}
+	for i := 1; i < n && d < max; i++ {
+		d *= mul
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// RetryError is an error of a factory with RetryPolicy, which failed at all
+// attempts.
+type RetryError struct {
+	// Errs is errors of each attempt.
+	Errs []error
+
+	// Cause is an error which stopped retrying, like cancellation of the
+	// context. It is nil when attempts are exhausted or the last error is
+	// not retryable.
+	Cause error
+}
+
+func (e *RetryError) Error() string {
+	var b strings.Builder
+	if e.Cause != nil {
+		fmt.Fprintf(&b, "retry stopped after %d attempts: %s", len(e.Errs), e.Cause)
+	} else {
+		fmt.Fprintf(&b, "failed after %d attempts", len(e.Errs))
+	}
+	for i, err := range e.Errs {
+		fmt.Fprintf(&b, "; attempt %d: %s", i+1, err)
+	}
+	return b.String()
+}
+
+// Unwrap returns errors of all attempts, and Cause if available.
+func (e *RetryError) Unwrap() []error {
+	if e.Cause == nil {
+		return e.Errs
+	}
+	return append(append([]error(nil), e.Errs...), e.Cause)
+}
+
+// retry calls the factory of x until it succeeds, with the policy.
+func (p *RetryPolicy) retry(x *Context) (reflect.Value, error) {
+	var errs []error
+	for n := 1; ; n++ {
+		v, err := callFuncOnce(x)
+		if err == nil {
+			return v, nil
+		}
+		errs = append(errs, err)
+		if n >= p.MaxAttempts || !p.retryable(err) {
+			return reflect.Value{}, &RetryError{Errs: errs}
+		}
+		x.reset()
+		ctx := x.Context()
+		t := time.NewTimer(p.backoff(n))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return reflect.Value{}, &RetryError{Errs: errs, Cause: ctx.Err()}
+		case <-t.C:
+		}
+	}
+}
+
+// callFunc calls the factory of x, with retries when it has RetryPolicy.
+func callFunc(x *Context) (reflect.Value, error) {
+	if p := x.f.Retry; p != nil {
+		return p.retry(x)
+	}
+	return callFuncOnce(x)
+}
+
+// AddRetry adds a function as Factory, which is retried with the policy
+// when it fails.
+func (m *Materializer) AddRetry(policy RetryPolicy, fn interface{}, tags ...string) error {
+	return m.add(1, fn, tags, func(f *Factory) {
+		f.Retry = &policy
+	})
+}
@@ -0,0 +1,151 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestRetry(t *testing.T) {
+	m := newTestMaterializer(t)
+	var n int
+	err := m.AddRetry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	}, func() (*Foo, error) {
+		n++
+		if n < 3 {
+			return nil, errTransient
+		}
+		return &Foo{id: n}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if foo.id != 3 {
+		t.Errorf("unexpected attempts: %d", foo.id)
+	}
+}
+
+func TestRetry_Exhausted(t *testing.T) {
+	m := newTestMaterializer(t)
+	var n int
+	m.AddRetry(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}, func() (*Foo, error) {
+		n++
+		return nil, errTransient
+	})
+	var foo *Foo
+	err := m.Materialize(&foo)
+	if n != 2 {
+		t.Errorf("unexpected attempts: %d", n)
+	}
+	var re *RetryError
+	if !errors.As(err, &re) || len(re.Errs) != 2 || re.Cause != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, errTransient) {
+		t.Errorf("error should wrap errors of attempts: %v", err)
+	}
+	if s := err.Error(); !strings.HasSuffix(s, "failed after 2 attempts; attempt 1: factory for *materialize.Foo failed: transient; attempt 2: factory for *materialize.Foo failed: transient") {
+		t.Errorf("unexpected message: %s", s)
+	}
+}
+
+func TestRetry_NotRetryable(t *testing.T) {
+	m := newTestMaterializer(t)
+	fatal := errors.New("fatal")
+	var n int
+	m.AddRetry(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		Retryable: func(err error) bool {
+			return errors.Is(err, errTransient)
+		},
+	}, func() (*Foo, error) {
+		n++
+		if n == 1 {
+			return nil, errTransient
+		}
+		return nil, fatal
+	})
+	var foo *Foo
+	err := m.Materialize(&foo)
+	if n != 2 || !errors.Is(err, fatal) {
+		t.Errorf("unexpected result: attempts=%d err=%v", n, err)
+	}
+}
+
+func TestRetry_Canceled(t *testing.T) {
+	m := newTestMaterializer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	m.AddRetry(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Hour,
+	}, func() (*Foo, error) {
+		n++
+		cancel()
+		return nil, errTransient
+	})
+	report := m.Warmup(ctx, WarmupOptions{})
+	err := report.Err()
+	if n != 1 || !errors.Is(err, context.Canceled) || !errors.Is(err, errTransient) {
+		t.Errorf("unexpected result: attempts=%d err=%v", n, err)
+	}
+}
+
+func TestRetry_Cleanup(t *testing.T) {
+	m := newTestMaterializer(t)
+	var n, cleaned int
+	m.AddRetry(RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}, func() (*Foo, func(), error) {
+		n++
+		cleanup := func() { cleaned++ }
+		if n == 1 {
+			return nil, cleanup, errTransient
+		}
+		return &Foo{}, cleanup, nil
+	})
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if cleaned != 1 {
+		t.Errorf("cleanup of failed attempt should be called: %d", cleaned)
+	}
+	m.CloseAll()
+	if cleaned != 2 {
+		t.Errorf("cleanup of the instance should be called: %d", cleaned)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+	for i, want := range []time.Duration{10, 20, 40, 50, 50} {
+		if d := p.backoff(i + 1); d != want*time.Millisecond {
+			t.Errorf("unexpected backoff #%d: %s", i+1, d)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.backoff(2); d <= 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("backoff with jitter out of range: %s", d)
+		}
+	}
+}
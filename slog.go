@@ -0,0 +1,82 @@
+package materialize
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithSlog replaces a *slog.Logger for structured logging of
+// materialization events. Each record has "type" and "tags" attributes. nil
+// disables it.
+func (m *Materializer) WithSlog(l *slog.Logger) *Materializer {
+	m.slog = l
+	m.slogHooks = Hooks{}
+	if l != nil {
+		m.slogHooks = slogHooks(l)
+	}
+	return m
+}
+
+func slogHooks(l *slog.Logger) Hooks {
+	return Hooks{
+		OnQuery: func(ev Event) {
+			if ev.Err != nil {
+				slogEvent(l, slog.LevelDebug, "factory not found", ev)
+				return
+			}
+			slogEvent(l, slog.LevelDebug, "factory selected", ev,
+				slog.Int("score", ev.Score))
+		},
+		OnCreateStart: func(ev Event) {
+			slogEvent(l, slog.LevelDebug, "creating instance", ev)
+		},
+		OnCreateDone: func(ev Event) {
+			if ev.Err != nil {
+				slogEvent(l, slog.LevelError, "failed to create instance", ev,
+					slog.Duration("duration", ev.Duration))
+				return
+			}
+			slogEvent(l, slog.LevelInfo, "created instance", ev,
+				slog.Duration("duration", ev.Duration))
+		},
+		OnCacheHit: func(ev Event) {
+			slogEvent(l, slog.LevelDebug, "cache hit", ev)
+		},
+		OnClose: func(ev Event) {
+			if ev.Err != nil {
+				slogEvent(l, slog.LevelError, "failed to close instance", ev,
+					slog.Duration("duration", ev.Duration))
+				return
+			}
+			slogEvent(l, slog.LevelDebug, "closed instance", ev,
+				slog.Duration("duration", ev.Duration))
+		},
+	}
+}
+
+func slogEvent(l *slog.Logger, level slog.Level, msg string, ev Event, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	tags := ev.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	attrs = append([]slog.Attr{
+		slog.String("type", ev.Type.String()),
+		slog.Any("tags", tags),
+	}, attrs...)
+	if f := ev.Factory; f != nil {
+		if f.Name != "" {
+			attrs = append(attrs, slog.String("name", f.Name))
+		}
+		if src := f.Source(); src != "" {
+			attrs = append(attrs, slog.String("source", src))
+		}
+	}
+	if ev.Err != nil {
+		attrs = append(attrs, slog.Any("error", ev.Err))
+	}
+	l.LogAttrs(ctx, level, msg, attrs...)
+}
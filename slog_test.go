@@ -0,0 +1,87 @@
+package materialize
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithSlog(t *testing.T) {
+	var sink []string
+	bb := &bytes.Buffer{}
+	l := slog.New(slog.NewJSONHandler(bb, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	m := newTestMaterializer(t).WithSlog(l)
+	m.MustAdd(func() *Res1 { return &Res1{sink: &sink, id: "res1", errStr: "oops"} }, "foo")
+
+	var r1 *Res1
+	if err := m.Materialize(&r1, "foo"); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	if err := m.Materialize(&r1, "foo"); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	m.CloseAll()
+
+	var got []string
+	for _, s := range strings.Split(strings.TrimSpace(bb.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(s), &rec); err != nil {
+			t.Fatalf("failed to parse log: %s", err)
+		}
+		if rec["type"] != "*materialize.Res1" {
+			t.Errorf("unexpected type attribute: %v", rec["type"])
+		}
+		if tags, ok := rec["tags"].([]interface{}); !ok || len(tags) != 1 || tags[0] != "foo" {
+			t.Errorf("unexpected tags attribute: %v", rec["tags"])
+		}
+		got = append(got, rec["level"].(string)+" "+rec["msg"].(string))
+	}
+	exp := []string{
+		"DEBUG factory selected",
+		"DEBUG creating instance",
+		"INFO created instance",
+		"DEBUG factory selected",
+		"DEBUG cache hit",
+		"ERROR failed to close instance",
+	}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Fatalf("unexpected logs:\n%s", bb.String())
+	}
+}
+
+func TestWithSlog_Replace(t *testing.T) {
+	bb1, bb2 := &bytes.Buffer{}, &bytes.Buffer{}
+	m := newTestMaterializer(t).
+		WithSlog(slog.New(slog.NewTextHandler(bb1, nil))).
+		WithSlog(slog.New(slog.NewTextHandler(bb2, nil)))
+	m.MustAdd(newFoo)
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if bb1.Len() != 0 {
+		t.Errorf("replaced logger should not be used:\n%s", bb1.String())
+	}
+	if !strings.Contains(bb2.String(), "created instance") {
+		t.Errorf("unexpected logs:\n%s", bb2.String())
+	}
+
+	m.WithSlog(nil)
+	m.CloseAll()
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(bb2.String(), "created instance"); n != 1 {
+		t.Errorf("disabled logger should not be used:\n%s", bb2.String())
+	}
+}
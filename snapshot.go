@@ -0,0 +1,102 @@
+package materialize
+
+import "time"
+
+// maxCloseErrors is the number of recent close errors which are kept for
+// Snapshot.
+const maxCloseErrors = 100
+
+// Snapshot is a state of a Materializer for introspection.
+type Snapshot struct {
+	// Factories is registered factories ordered by type and tags.
+	Factories []FactoryInfo
+
+	// Instances is cached instances in order of creation.
+	Instances []InstanceInfo
+
+	// CloseErrors is recent errors of close, oldest first.
+	CloseErrors []CloseError
+}
+
+// FactoryRef identifies a factory by its type and tags.
+type FactoryRef struct {
+	Type string
+	Tags []string
+}
+
+// FactoryInfo describes a registered factory.
+type FactoryInfo struct {
+	FactoryRef
+	Name        string
+	Description string
+	Source      string
+	Priority    int
+	Conditional bool
+	Module      string
+}
+
+// InstanceInfo describes a cached instance.
+type InstanceInfo struct {
+	FactoryRef
+	Name   string
+	Source string
+
+	// Created is when the creation started, and Duration is how long it
+	// took.
+	Created  time.Time
+	Duration time.Duration
+
+	// Deps is factories of instances which the instance depends on directly.
+	Deps []FactoryRef
+}
+
+// CloseError is an error which happened on close of an instance.
+type CloseError struct {
+	Type   string
+	Tags   []string
+	Name   string
+	Source string
+	Time   time.Time
+	Err    error `json:"-"`
+	Error  string
+}
+
+func factoryRef(f *Factory) FactoryRef {
+	return FactoryRef{Type: f.Type.String(), Tags: f.Tags.list()}
+}
+
+// Snapshot returns a snapshot of registered factories, cached instances and
+// recent close errors. This doesn't wait for a running materialization.
+func (m *Materializer) Snapshot() Snapshot {
+	var s Snapshot
+	for _, f := range m.getRepo().factories() {
+		s.Factories = append(s.Factories, FactoryInfo{
+			FactoryRef:  factoryRef(f),
+			Name:        f.Name,
+			Description: f.Description,
+			Source:      f.Source(),
+			Priority:    f.Priority,
+			Conditional: f.Cond != nil,
+			Module:      f.module,
+		})
+	}
+	for _, e := range m.cache.snapshot() {
+		info := InstanceInfo{
+			FactoryRef: factoryRef(e.fac),
+			Name:       e.fac.Name,
+			Source:     e.fac.Source(),
+			Created:    e.created,
+			Duration:   e.duration,
+		}
+		seen := map[*Factory]bool{}
+		for _, d := range e.deps {
+			if !seen[d] {
+				seen[d] = true
+				info.Deps = append(info.Deps, factoryRef(d))
+			}
+		}
+		s.Instances = append(s.Instances, info)
+	}
+	s.CloseErrors = m.stats.closeErrors()
+	return s
+}
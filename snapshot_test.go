@@ -0,0 +1,101 @@
+package materialize
+
+import (
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type brokenCloser struct{}
+
+func (brokenCloser) Close() error {
+	return errors.New("close failed")
+}
+
+func TestSnapshot(t *testing.T) {
+	m := newTestMaterializer(t).WithLogger(log.New(io.Discard, "", 0))
+	m.MustAdd(newFoo)
+	m.AddNamed("bar", "Bar with Foo", func(x *Context) *Bar {
+		var foo *Foo
+		x.Materialize(&foo).Materialize(&foo)
+		return &Bar{}
+	}, "b")
+	m.MustAdd(func() *brokenCloser { return &brokenCloser{} })
+
+	var bar *Bar
+	if err := m.Materialize(&bar, "b"); err != nil {
+		t.Fatal(err)
+	}
+	s := m.Snapshot()
+	if len(s.Factories) != 3 {
+		t.Fatalf("unexpected factories: %+v", s.Factories)
+	}
+	fi := s.Factories[0]
+	if fi.Type != "*materialize.Bar" || !reflect.DeepEqual(fi.Tags, []string{"b"}) || fi.Name != "bar" || fi.Description != "Bar with Foo" || fi.Source == "" {
+		t.Errorf("unexpected factory info: %+v", fi)
+	}
+	if len(s.Instances) != 2 {
+		t.Fatalf("unexpected instances: %+v", s.Instances)
+	}
+	foo, bi := s.Instances[0], s.Instances[1]
+	if foo.Type != "*materialize.Foo" || foo.Created.IsZero() || len(foo.Deps) != 0 {
+		t.Errorf("unexpected instance of Foo: %+v", foo)
+	}
+	if bi.Type != "*materialize.Bar" || bi.Name != "bar" || !reflect.DeepEqual(bi.Deps, []FactoryRef{{Type: "*materialize.Foo", Tags: []string{}}}) {
+		t.Errorf("unexpected instance of Bar: %+v", bi)
+	}
+	if bi.Created.After(foo.Created) {
+		t.Errorf("creation of Bar should start before Foo: %s, %s", bi.Created, foo.Created)
+	}
+
+	var bc *brokenCloser
+	if err := m.Materialize(&bc); err != nil {
+		t.Fatal(err)
+	}
+	m.CloseAll()
+	s = m.Snapshot()
+	if len(s.Instances) != 0 {
+		t.Errorf("instances should be empty: %+v", s.Instances)
+	}
+	if len(s.CloseErrors) != 1 {
+		t.Fatalf("unexpected close errors: %+v", s.CloseErrors)
+	}
+	if ce := s.CloseErrors[0]; ce.Type != "*materialize.brokenCloser" || ce.Error != "close failed" || ce.Time.IsZero() {
+		t.Errorf("unexpected close error: %+v", ce)
+	}
+}
+
+func TestSnapshot_Materializing(t *testing.T) {
+	m := newTestMaterializer(t)
+	entered, release := make(chan struct{}), make(chan struct{})
+	m.MustAdd(func() *Foo {
+		close(entered)
+		<-release
+		return &Foo{}
+	})
+	done := make(chan error)
+	go func() {
+		var foo *Foo
+		done <- m.Materialize(&foo)
+	}()
+	<-entered
+	got := make(chan Snapshot)
+	go func() {
+		got <- m.Snapshot()
+	}()
+	select {
+	case s := <-got:
+		if len(s.Factories) != 1 || len(s.Instances) != 0 {
+			t.Errorf("unexpected snapshot: %+v", s)
+		}
+	case <-time.After(time.Second):
+		t.Error("snapshot should not wait for materialization")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,213 @@
+package materialize
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+)
+
+// histogramBounds is upper bounds of buckets for duration histograms in
+// FactoryStats. The last bucket of a histogram counts durations over the
+// largest bound.
+var histogramBounds = [...]time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Histogram counts durations for each bucket of Stats.HistogramBounds.
+type Histogram []int64
+
+func newHistogram() Histogram {
+	return make(Histogram, len(histogramBounds)+1)
+}
+
+func (h Histogram) observe(d time.Duration) {
+	for i, b := range histogramBounds {
+		if d <= b {
+			h[i]++
+			return
+		}
+	}
+	h[len(h)-1]++
+}
+
+// FactoryStats is statistics for a factory.
+type FactoryStats struct {
+	Type string
+	Tags []string
+
+	// Name is a name of the factory, and Source is where the factory is
+	// registered. Those are empty when unknown.
+	Name   string
+	Source string
+
+	// Created is number of instances which created successfully.
+	Created int64
+	// Failed is number of failures of creation.
+	Failed int64
+	// CacheHits is number of instances which taken from the cache.
+	CacheHits int64
+	// CacheMisses is number of queries which required creation.
+	CacheMisses int64
+	// Closed is number of instances which closed.
+	Closed int64
+	// CloseErrors is number of failures of close.
+	CloseErrors int64
+
+	// TotalDuration is cumulative time of creation.
+	TotalDuration time.Duration
+	// LastDuration is time of the last creation.
+	LastDuration time.Duration
+	// Durations is a histogram of time of creation.
+	Durations Histogram
+
+	// CloseDuration is cumulative time of close.
+	CloseDuration time.Duration
+	// CloseDurations is a histogram of time of close.
+	CloseDurations Histogram
+}
+
+// Stats is a snapshot of statistics of a Materializer.
+type Stats struct {
+	Factories []FactoryStats
+
+	// HistogramBounds is upper bounds of buckets for histograms in
+	// Factories. The last bucket counts durations over the largest bound.
+	HistogramBounds []time.Duration
+}
+
+// stats collects statistics for each factory.
+type stats struct {
+	mu  sync.Mutex
+	fss map[*Factory]*FactoryStats
+
+	// closeErrs is recent errors of close, up to maxCloseErrors.
+	closeErrs []CloseError
+}
+
+func newStats() *stats {
+	return &stats{fss: map[*Factory]*FactoryStats{}}
+}
+
+func (s *stats) get(f *Factory) *FactoryStats {
+	fs, ok := s.fss[f]
+	if !ok {
+		fs = &FactoryStats{
+			Type:           f.Type.String(),
+			Tags:           f.Tags.list(),
+			Name:           f.Name,
+			Source:         f.Source(),
+			Durations:      newHistogram(),
+			CloseDurations: newHistogram(),
+		}
+		s.fss[f] = fs
+	}
+	return fs
+}
+
+func (s *stats) update(ev Event, fn func(*FactoryStats)) {
+	s.mu.Lock()
+	fn(s.get(ev.Factory))
+	s.mu.Unlock()
+}
+
+func (s *stats) hooks() Hooks {
+	return Hooks{
+		OnCreateStart: func(ev Event) {
+			s.update(ev, func(fs *FactoryStats) {
+				fs.CacheMisses++
+			})
+		},
+		OnCreateDone: func(ev Event) {
+			s.update(ev, func(fs *FactoryStats) {
+				if ev.Err != nil {
+					fs.Failed++
+				} else {
+					fs.Created++
+				}
+				fs.TotalDuration += ev.Duration
+				fs.LastDuration = ev.Duration
+				fs.Durations.observe(ev.Duration)
+			})
+		},
+		OnCacheHit: func(ev Event) {
+			s.update(ev, func(fs *FactoryStats) {
+				fs.CacheHits++
+			})
+		},
+		OnClose: func(ev Event) {
+			s.update(ev, func(fs *FactoryStats) {
+				fs.Closed++
+				if ev.Err != nil {
+					fs.CloseErrors++
+					s.addCloseError(ev)
+				}
+				fs.CloseDuration += ev.Duration
+				fs.CloseDurations.observe(ev.Duration)
+			})
+		},
+	}
+}
+
+// addCloseError records an error of close. The oldest one is dropped when
+// there are too many errors. s.mu should be locked.
+func (s *stats) addCloseError(ev Event) {
+	if len(s.closeErrs) >= maxCloseErrors {
+		s.closeErrs = append(s.closeErrs[:0], s.closeErrs[1:]...)
+	}
+	s.closeErrs = append(s.closeErrs, CloseError{
+		Type:   ev.Type.String(),
+		Tags:   ev.Tags,
+		Name:   ev.Factory.Name,
+		Source: ev.Factory.Source(),
+		Time:   time.Now(),
+		Err:    ev.Err,
+		Error:  ev.Err.Error(),
+	})
+}
+
+func (s *stats) closeErrors() []CloseError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]CloseError(nil), s.closeErrs...)
+}
+
+func (s *stats) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Stats{
+		Factories:       make([]FactoryStats, 0, len(s.fss)),
+		HistogramBounds: append([]time.Duration(nil), histogramBounds[:]...),
+	}
+	for _, fs := range s.fss {
+		v := *fs
+		v.Durations = append(Histogram(nil), fs.Durations...)
+		v.CloseDurations = append(Histogram(nil), fs.CloseDurations...)
+		st.Factories = append(st.Factories, v)
+	}
+	sort.Slice(st.Factories, func(i, j int) bool {
+		a, b := st.Factories[i], st.Factories[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return newTags(a.Tags).joinKeys() < newTags(b.Tags).joinKeys()
+	})
+	return st
+}
+
+// Stats returns a snapshot of statistics for each factory.
+func (m *Materializer) Stats() Stats {
+	return m.stats.snapshot()
+}
+
+// PublishExpvar publishes statistics of the Materializer with expvar.
+// This panics if the name is already used, same as expvar.Publish.
+func (m *Materializer) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return m.Stats()
+	}))
+}
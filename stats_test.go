@@ -0,0 +1,103 @@
+package materialize
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	var sink []string
+	m := newTestMaterializer(t).WithLogger(log.New(io.Discard, "", 0))
+	m.MustAdd(newFoo)
+	m.MustAdd(func() (*Bar, error) { return nil, errors.New("no bar") })
+	m.MustAdd(func() *Res1 { return &Res1{sink: &sink, id: "res1", errStr: "oops"} }, "r")
+
+	for i := 0; i < 3; i++ {
+		var foo *Foo
+		if err := m.Materialize(&foo); err != nil {
+			t.Fatalf("failed to materialize *Foo: %s", err)
+		}
+	}
+	var bar *Bar
+	if err := m.Materialize(&bar); err == nil {
+		t.Fatal("materialize *Bar should be failed")
+	}
+	var r1 *Res1
+	if err := m.Materialize(&r1, "r"); err != nil {
+		t.Fatalf("failed to materialize *Res1: %s", err)
+	}
+	m.CloseAll()
+
+	st := m.Stats()
+	if len(st.Factories) != 3 {
+		t.Fatalf("unexpected number of factories: %+v", st)
+	}
+	check := func(fs FactoryStats, typ string, created, failed, hits, misses, closed, closeErrs int64) {
+		t.Helper()
+		if fs.Type != typ {
+			t.Fatalf("unexpected type: %s (expected %s)", fs.Type, typ)
+		}
+		if fs.Created != created || fs.Failed != failed ||
+			fs.CacheHits != hits || fs.CacheMisses != misses ||
+			fs.Closed != closed || fs.CloseErrors != closeErrs {
+			t.Errorf("unexpected stats for %s: %+v", typ, fs)
+		}
+		var n int64
+		for _, c := range fs.Durations {
+			n += c
+		}
+		if n != created+failed {
+			t.Errorf("unexpected histogram for %s: %+v", typ, fs.Durations)
+		}
+	}
+	check(st.Factories[0], "*materialize.Bar", 0, 1, 0, 1, 0, 0)
+	check(st.Factories[1], "*materialize.Foo", 1, 0, 2, 1, 0, 0)
+	check(st.Factories[2], "*materialize.Res1", 1, 0, 0, 1, 1, 1)
+	if tags := st.Factories[2].Tags; len(tags) != 1 || tags[0] != "r" {
+		t.Errorf("unexpected tags: %+v", tags)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	h.observe(0)
+	h.observe(time.Millisecond)
+	h.observe(50 * time.Millisecond)
+	h.observe(time.Minute)
+	exp := Histogram{2, 0, 1, 0, 0, 1}
+	for i := range exp {
+		if h[i] != exp[i] {
+			t.Fatalf("unexpected histogram: %+v (expected %+v)", h, exp)
+		}
+	}
+}
+
+var expvarSeq int32
+
+func TestPublishExpvar(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatalf("failed to materialize *Foo: %s", err)
+	}
+	name := fmt.Sprintf("materialize_test_%d", atomic.AddInt32(&expvarSeq, 1))
+	m.PublishExpvar(name)
+	var st Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &st); err != nil {
+		t.Fatalf("failed to parse expvar: %s", err)
+	}
+	if len(st.Factories) != 1 || st.Factories[0].Created != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if len(st.HistogramBounds) != len(st.Factories[0].Durations)-1 || st.HistogramBounds[0] != time.Millisecond {
+		t.Fatalf("unexpected histogram bounds: %+v", st.HistogramBounds)
+	}
+}
@@ -53,3 +53,23 @@ func (tags Tags) joinKeys() string {
 	sort.Strings(keys)
 	return strings.Join(keys, " ")
 }
+
+// list returns sorted tags as a slice.
+func (tags Tags) list() []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// contains checks all of other tags are included.
+func (tags Tags) contains(other Tags) bool {
+	for t := range other {
+		if _, ok := tags[t]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package materialize
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TimeoutError is an error of a factory which didn't create an instance in
+// its Timeout.
+type TimeoutError struct {
+	Type    reflect.Type
+	Tags    []string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("factory for %s tags:%+v timed out after %s", e.Type, e.Tags, e.Timeout)
+}
+
+// Is reports the error matches context.DeadlineExceeded.
+func (e *TimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+type funcResult struct {
+	v   reflect.Value
+	err error
+}
+
+// invoke calls the factory of x, with the timeout of the factory. When it
+// times out, the call is abandoned: its context is canceled, further
+// materialization with it fails, and an instance created after that is
+// closed.
+func invoke(x *Context) (reflect.Value, error) {
+	f := x.f
+	if f.Timeout <= 0 {
+		return callFunc(x)
+	}
+	ctx, cancel := context.WithTimeout(x.Context(), f.Timeout)
+	defer cancel()
+	x.ctx = ctx
+	done := make(chan funcResult, 1)
+	go func() {
+		v, err := callFunc(x)
+		done <- funcResult{v: v, err: err}
+	}()
+	t := time.NewTimer(f.Timeout)
+	defer t.Stop()
+	select {
+	case r := <-done:
+		return r.v, r.err
+	case <-t.C:
+	}
+	err := &TimeoutError{Type: f.Type, Tags: f.Tags.list(), Timeout: f.Timeout}
+	x.abandon(err)
+	go closeAbandoned(x, done)
+	return reflect.Value{}, err
+}
+
+// closeAbandoned closes an instance which is created by an abandoned call.
+func closeAbandoned(x *Context, done <-chan funcResult) {
+	r := <-done
+	if r.err != nil {
+		return
+	}
+	if c := toCloseFunc(r.v, x.cleanup); c != nil {
+		if err := c(); err != nil {
+			x.m.logf("failed to close abandoned %s: %s", x.f.Type, err)
+		}
+	}
+}
+
+// AddTimeout adds a function as Factory, which fails with TimeoutError when
+// it doesn't create an instance in the timeout.
+func (m *Materializer) AddTimeout(timeout time.Duration, fn interface{}, tags ...string) error {
+	return m.add(1, fn, tags, func(f *Factory) {
+		f.Timeout = timeout
+	})
+}
@@ -0,0 +1,105 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type abandonedRes struct {
+	closed chan struct{}
+}
+
+func (r *abandonedRes) Close() {
+	close(r.closed)
+}
+
+func TestTimeout(t *testing.T) {
+	m := newTestMaterializer(t)
+	res := &abandonedRes{closed: make(chan struct{})}
+	release := make(chan struct{})
+	err := m.AddTimeout(10*time.Millisecond, func(x *Context) *abandonedRes {
+		<-x.Context().Done()
+		<-release
+		return res
+	}, "slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.MustAdd(newFoo)
+
+	var r *abandonedRes
+	err = m.Materialize(&r, "slow")
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if te.Type != reflect.TypeOf(res) || !reflect.DeepEqual(te.Tags, []string{"slow"}) || te.Timeout != 10*time.Millisecond {
+		t.Errorf("unexpected timeout error: %+v", te)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error should match context.DeadlineExceeded: %v", err)
+	}
+	if s := te.Error(); s != "factory for *materialize.abandonedRes tags:[slow] timed out after 10ms" {
+		t.Errorf("unexpected message: %s", s)
+	}
+
+	// other materializations are not blocked.
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+
+	// an instance created by the abandoned call is closed.
+	close(release)
+	select {
+	case <-res.closed:
+	case <-time.After(time.Second):
+		t.Error("abandoned instance is not closed")
+	}
+}
+
+func TestTimeout_Abandoned(t *testing.T) {
+	m := newTestMaterializer(t)
+	var nFoo int32
+	m.MustAdd(func() *Foo {
+		atomic.AddInt32(&nFoo, 1)
+		return &Foo{}
+	})
+	release := make(chan struct{})
+	done := make(chan error, 1)
+	err := m.AddTimeout(10*time.Millisecond, func(x *Context) *Bar {
+		<-release
+		var foo *Foo
+		done <- x.Materialize(&foo).Error()
+		return &Bar{}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bar *Bar
+	if err := m.Materialize(&bar); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	close(release)
+	var te *TimeoutError
+	if err := <-done; !errors.As(err, &te) {
+		t.Errorf("abandoned call should not materialize: %v", err)
+	}
+	if n := atomic.LoadInt32(&nFoo); n != 0 {
+		t.Errorf("dependency is created by abandoned call: %d", n)
+	}
+}
+
+func TestTimeout_InTime(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.AddTimeout(time.Second, newFoo)
+	var foo *Foo
+	if err := m.Materialize(&foo); err != nil {
+		t.Fatal(err)
+	}
+}
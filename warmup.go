@@ -0,0 +1,111 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// WarmupOptions is options for Materializer.Warmup.
+type WarmupOptions struct {
+	// Concurrency is max number of factories which run concurrently.
+	// Default is 1.
+	Concurrency int
+
+	// Tags filters factories. Only factories which have all of these tags
+	// are materialized.
+	Tags []string
+}
+
+// WarmupResult is a result of materialization of a factory.
+type WarmupResult struct {
+	Type     reflect.Type
+	Tags     []string
+	Duration time.Duration
+	Err      error
+}
+
+// WarmupReport is a report of Materializer.Warmup.
+type WarmupReport struct {
+	Results  []WarmupResult
+	Duration time.Duration
+}
+
+// Err returns joined errors of failed results, or nil.
+func (r *WarmupReport) Err() error {
+	var errs []error
+	for _, res := range r.Results {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Warmup materializes all registered factories which match with options
+// concurrently. Factories which are never selected for their type and tags,
+// like unavailable conditional ones or shadowed fallbacks, are skipped.
+// Dependencies are shared between factories, a factory waits for its
+// dependencies under creation by others.
+func (m *Materializer) Warmup(ctx context.Context, opts WarmupOptions) *WarmupReport {
+	if m.currRootX.Load() != nil {
+		return &WarmupReport{Results: []WarmupResult{{Err: ErrorBusy}}}
+	}
+	m.mu.Lock()
+	defer func() {
+		m.currRootX.Store(nil)
+		m.mu.Unlock()
+	}()
+	m.currRootX.Store(&Context{m: m, ctx: ctx})
+
+	filter := newTags(opts.Tags)
+	r := m.getRepo()
+	var facs []*Factory
+	for _, f := range r.factories() {
+		if f.Tags.contains(filter) && r.selects(m, f) {
+			facs = append(facs, f)
+		}
+	}
+	n := opts.Concurrency
+	if n < 1 {
+		n = 1
+	}
+
+	st := time.Now()
+	report := &WarmupReport{Results: make([]WarmupResult, len(facs))}
+	ch := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				report.Results[j] = m.warmup(ctx, facs[j])
+			}
+		}()
+	}
+	for i := range facs {
+		ch <- i
+	}
+	close(ch)
+	wg.Wait()
+	report.Duration = time.Since(st)
+	return report
+}
+
+func (m *Materializer) warmup(ctx context.Context, f *Factory) WarmupResult {
+	st := time.Now()
+	err := ctx.Err()
+	if err == nil {
+		x := &Context{m: m, ctx: ctx}
+		err = m.materializeFactory(x, reflect.New(f.Type), f)
+	}
+	return WarmupResult{
+		Type:     f.Type,
+		Tags:     f.Tags.list(),
+		Duration: time.Since(st),
+		Err:      err,
+	}
+}
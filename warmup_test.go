@@ -0,0 +1,210 @@
+package materialize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	var nFoo, running, maxRunning int32
+	enter := func() {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+	m := newTestMaterializer(t)
+	m.MustAdd(func() *Foo {
+		atomic.AddInt32(&nFoo, 1)
+		enter()
+		return &Foo{}
+	}, "warm")
+	m.MustAdd(func() *Bar {
+		enter()
+		return &Bar{}
+	}, "warm")
+	m.MustAdd(func(x *Context) *FooX {
+		v := &FooX{}
+		x.Materialize(&v.foo, "warm").Materialize(&v.bar, "warm")
+		enter()
+		return v
+	}, "warm")
+	m.MustAdd(func() (*FooBar, error) {
+		return nil, errors.New("broken")
+	}, "warm", "broken")
+	m.MustAdd(func() string {
+		t.Error("string should not be materialized")
+		return ""
+	}, "cold")
+
+	report := m.Warmup(context.Background(), WarmupOptions{
+		Concurrency: 4,
+		Tags:        []string{"warm"},
+	})
+	if len(report.Results) != 4 {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+	for _, r := range report.Results {
+		if r.Type.String() == "*materialize.FooBar" {
+			if r.Err == nil || r.Err.Error() != fmt.Sprintf("factory failed (registered at %s): factory for *materialize.FooBar failed: broken", sourceOf(t, m, (*FooBar)(nil), "broken")) {
+				t.Errorf("unexpected error for %s: %v", r.Type, r.Err)
+			}
+			continue
+		}
+		if r.Err != nil {
+			t.Errorf("failed to warmup %s: %s", r.Type, r.Err)
+		}
+	}
+	if err := report.Err(); err == nil {
+		t.Error("report should have an error")
+	}
+	if n := atomic.LoadInt32(&nFoo); n != 1 {
+		t.Errorf("*Foo should be created once: %d", n)
+	}
+	if n := atomic.LoadInt32(&maxRunning); n < 2 {
+		t.Errorf("factories should run concurrently: %d", n)
+	}
+
+	var fooX *FooX
+	if err := m.Materialize(&fooX, "warm"); err != nil {
+		t.Fatalf("failed to materialize *FooX: %s", err)
+	}
+	for _, fs := range m.Stats().Factories {
+		if fs.Type == "*materialize.FooX" && fs.CacheHits != 1 {
+			t.Errorf("*FooX should be cached: %+v", fs)
+		}
+	}
+}
+
+func TestWarmup_Circular(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func(x *Context) *Circular1A {
+		v := &Circular1A{}
+		time.Sleep(10 * time.Millisecond)
+		x.Resolve(v).Materialize(&v.b)
+		return v
+	}).MustAdd(func(x *Context) *Circular1B {
+		v := &Circular1B{}
+		time.Sleep(10 * time.Millisecond)
+		x.Resolve(v).Materialize(&v.a)
+		return v
+	})
+	report := m.Warmup(context.Background(), WarmupOptions{Concurrency: 2})
+	if err := report.Err(); err != nil {
+		t.Fatalf("failed to warmup: %s", err)
+	}
+	var a *Circular1A
+	if err := m.Materialize(&a); err != nil {
+		t.Fatalf("failed to materialize: %s", err)
+	}
+	if a.b.a != a {
+		t.Fatal("circular references are broken")
+	}
+}
+
+func TestWarmup_Deadlock(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(func(x *Context) *Circular1A {
+		v := &Circular1A{}
+		time.Sleep(10 * time.Millisecond)
+		x.Materialize(&v.b)
+		return v
+	}).MustAdd(func(x *Context) *Circular1B {
+		v := &Circular1B{}
+		time.Sleep(10 * time.Millisecond)
+		x.Materialize(&v.a)
+		return v
+	})
+	report := m.Warmup(context.Background(), WarmupOptions{Concurrency: 2})
+	for _, r := range report.Results {
+		if r.Err == nil {
+			t.Errorf("warmup %s should be failed", r.Type)
+		}
+	}
+}
+
+func TestWarmup_Canceled(t *testing.T) {
+	m := newTestMaterializer(t)
+	m.MustAdd(newFoo)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := m.Warmup(ctx, WarmupOptions{})
+	if len(report.Results) != 1 || !errors.Is(report.Results[0].Err, context.Canceled) {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+}
+
+func TestWarmup_Selection(t *testing.T) {
+	var called []string
+	newString := func(s string) func() string {
+		return func() string {
+			called = append(called, s)
+			return s
+		}
+	}
+	m := newTestMaterializer(t).WithProfiles("dev")
+	m.MustAdd(newString("normal"))
+	if err := m.AddDefault(newString("default")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddIf(Profile("prod"), newString("prod"), "env"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddIf(Profile("dev"), newString("dev"), "env"); err != nil {
+		t.Fatal(err)
+	}
+	r := m.Warmup(context.Background(), WarmupOptions{})
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Results) != 2 {
+		t.Errorf("unexpected results: %+v", r.Results)
+	}
+	if got := fmt.Sprint(called); got != "[normal dev]" {
+		t.Errorf("unexpected factories are called: %s", got)
+	}
+}
+
+func TestWarmup_DeadlockGo(t *testing.T) {
+	m := newTestMaterializer(t)
+	fooStarted, barStarted := make(chan struct{}), make(chan struct{})
+	m.MustAdd(func(x *Context) *FooX {
+		<-fooStarted
+		<-barStarted
+		v := &FooX{}
+		x.Go(&v.foo).Go(&v.bar).Wait()
+		return v
+	}).MustAdd(func() *Foo {
+		close(fooStarted)
+		time.Sleep(20 * time.Millisecond)
+		return &Foo{}
+	}).MustAdd(func(x *Context) *Bar {
+		close(barStarted)
+		// *FooX is waiting for *Bar, after *Foo is done.
+		time.Sleep(50 * time.Millisecond)
+		var v *FooX
+		x.Materialize(&v)
+		return &Bar{}
+	})
+	done := make(chan *WarmupReport)
+	go func() {
+		done <- m.Warmup(context.Background(), WarmupOptions{Concurrency: 3})
+	}()
+	select {
+	case report := <-done:
+		if report.Err() == nil {
+			t.Error("warmup should be failed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("warmup is deadlocked")
+	}
+}